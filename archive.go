@@ -0,0 +1,188 @@
+package fetch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrNoArchive is returned for bodies being neither gzip, zip nor tar.
+var ErrNoArchive = errors.New("body is no gzip, zip or tar archive")
+
+// ErrMemberNotFound is returned by Member().
+var ErrMemberNotFound = errors.New("archive member not found")
+
+// Members calls fn for each file of an archived response body.
+// Recognized are .zip, .tar, .tar.gz and plain .gz -
+// by magic bytes, not by url or content type, since
+// dataset publishers label these carelessly.
+// A plain .gz yields one member, named after the gzip header
+// or the url path without ".gz".
+// Returning io.EOF from fn stops the iteration without error.
+// Spilled bodies are read from their file;
+// streamed bodies are consumed and closed.
+func (j *Job) Members(fn func(name string, r io.Reader) error) error {
+	var body io.Reader = bytes.NewReader(j.bts)
+	switch {
+	case j.stream != nil:
+		defer j.stream.Close()
+		body = j.stream
+	case j.SpillPath != "":
+		rc, err := j.openSpill()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		body = rc
+	}
+	err := archiveMembers(body, j.archiveName(), fn)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// Member returns the contents of the named archive member.
+func (j *Job) Member(name string) ([]byte, error) {
+	var ret []byte
+	found := false
+	err := j.Members(func(n string, r io.Reader) error {
+		if n != name {
+			return nil
+		}
+		var err error
+		ret, err = ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		found = true
+		return io.EOF
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %v", ErrMemberNotFound, name)
+	}
+	return ret, nil
+}
+
+func (j *Job) archiveName() string {
+	p := ""
+	if j.Req != nil && j.Req.URL != nil {
+		p = j.Req.URL.Path
+	} else {
+		p = j.URL
+	}
+	p = path.Base(p)
+	return strings.TrimSuffix(p, ".gz")
+}
+
+// zipSource - zip needs random access;
+// files and byte readers have it, other bodies are read into memory.
+func zipSource(body io.Reader, br *bufio.Reader) (io.ReaderAt, int64, error) {
+	switch r := body.(type) {
+	case *bytes.Reader:
+		return r, r.Size(), nil
+	case *os.File:
+		fi, err := r.Stat()
+		if err != nil {
+			return nil, 0, err
+		}
+		return r, fi.Size(), nil
+	}
+	bts, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(bts), int64(len(bts)), nil
+}
+
+func archiveMembers(body io.Reader, gzName string, fn func(name string, r io.Reader) error) error {
+
+	br := bufio.NewReaderSize(body, 512)
+	magic, _ := br.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		ra, size, err := zipSource(body, br)
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			return err
+		}
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(zf.Name, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		gbr := bufio.NewReaderSize(gz, 512)
+		if isTar(gbr) {
+			return tarMembers(gbr, fn)
+		}
+		name := gz.Name
+		if name == "" {
+			name = gzName
+		}
+		return fn(name, gbr)
+
+	default:
+		if isTar(br) {
+			return tarMembers(br, fn)
+		}
+	}
+	return ErrNoArchive
+}
+
+// isTar checks for the ustar magic at offset 257
+func isTar(br *bufio.Reader) bool {
+	hdr, _ := br.Peek(262)
+	return len(hdr) == 262 && string(hdr[257:262]) == "ustar"
+}
+
+func tarMembers(r io.Reader, fn func(name string, r io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
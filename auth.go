@@ -0,0 +1,30 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"log/slog"
+)
+
+// BasicAuth sends user and pass as Authorization header.
+// The credentials are kept out of Msg, String() and results.
+func (j *Job) BasicAuth(user, pass string) {
+	j.authz = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	j.authzScheme = "Basic"
+}
+
+// BearerToken sends tok as Authorization header.
+// The token is kept out of Msg, String() and results.
+func (j *Job) BearerToken(tok string) {
+	j.authz = "Bearer " + tok
+	j.authzScheme = "Bearer"
+}
+
+// setAuth applies BasicAuth or BearerToken.
+// It overrides an Authorization header of a prebuilt request.
+func (f *Job) setAuth() {
+	if f.authz == "" {
+		return
+	}
+	f.Req.Header.Set("Authorization", f.authz)
+	f.log(slog.LevelInfo, "authorization [REDACTED]", "scheme", f.authzScheme)
+}
@@ -0,0 +1,181 @@
+package fetch
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Batch runs many jobs through a pool of workers.
+type Batch struct {
+	Jobs    []*Job
+	Workers int // default 8
+
+	// MaxInFlight caps concurrent fetches, should Workers be large.
+	// MaxInFlightPerHost caps concurrent fetches to any single host;
+	// jobs for a saturated host wait, while workers serve other hosts.
+	// Zero means no limit.
+	MaxInFlight        int
+	MaxInFlightPerHost int
+
+	// SamplePercent > 0 fetches only a stable sample of the jobs;
+	// see Sampled(). The others are marked as Skipped.
+	SamplePercent float64
+	SampleSeed    string
+
+	Jar     http.CookieJar // shared by all jobs without their own
+	Limiter *Limiter       // shared by all jobs without their own
+
+	OnChallenge ChallengeHandler // for all jobs without their own
+	Storm       *StormGuard      // for all jobs without their own
+	Breaker     *Breaker         // for all jobs without their own
+	Dedup       *Dedup           // for all jobs without their own
+	Cache       Cache            // for all jobs without their own
+
+	// RetryStatus and TerminalStatus apply to jobs without their own;
+	// see Job.RetryStatus.
+	RetryStatus    []int
+	TerminalStatus []int
+
+	// Config, if set, is consulted as each job is dispatched;
+	// its retry settings override those of the job.
+	// Jobs to hosts it does not allow are marked as Skipped.
+	Config *LiveConfig
+
+	// WarmUp runs once per host, before the first job against it.
+	// Set Jar to keep the session cookies.
+	WarmUp WarmUpFunc
+
+	started, finished time.Time
+
+	mu         sync.Mutex
+	pending    map[*Job]*PendingJob
+	hostBusy   map[string]int
+	hostParked map[string][]*Job
+	warm       map[string]*warmState
+}
+
+// NewBatch creates jobs for urls, each configured by opts.
+func NewBatch(urls []string, opts ...Option) *Batch {
+	b := &Batch{}
+	for _, u := range urls {
+		b.Jobs = append(b.Jobs, New(u, opts...))
+	}
+	return b
+}
+
+func (b *Batch) workers() int {
+	n := b.Workers
+	if n < 1 {
+		n = 8
+	}
+	if b.MaxInFlight > 0 && b.MaxInFlight < n {
+		n = b.MaxInFlight
+	}
+	return n
+}
+
+// Run fetches all jobs and returns them in their original order.
+// Errors remain in each job's Err.
+// Jobs not yet started when ctx is done are skipped;
+// their Err is set to ctx.Err().
+func (b *Batch) Run(ctx context.Context) []*Job {
+	for range b.Stream(ctx) {
+	}
+	return b.Jobs
+}
+
+// Stream fetches all jobs and delivers them in order of completion.
+// Jobs not sampled are not delivered.
+// The channel is closed after the last job; it must be drained.
+func (b *Batch) Stream(ctx context.Context) <-chan *Job {
+
+	queue := make(chan *Job)
+	done := make(chan *Job)
+	b.started, b.finished = time.Now(), time.Time{}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < b.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				for j != nil {
+					if ctx.Err() != nil {
+						j.Err = ctx.Err()
+					} else if err := b.warmUp(ctx, j); err != nil {
+						j.Err = err
+					} else {
+						b.track(j, "running")
+						j.onRetry = b.onRetry
+						j.FetchContext(ctx)
+						j.onRetry = nil
+					}
+					b.track(j, "")
+					next := b.releaseHost(j)
+					done <- j
+					j = next
+				}
+			}
+		}()
+	}
+
+	sampled := []*Job{}
+	for _, j := range b.Jobs {
+		if b.SamplePercent > 0 && !Sampled(j.URL, b.SamplePercent, b.SampleSeed) {
+			j.Skipped = "not sampled"
+			continue
+		}
+		b.track(j, "queued")
+		sampled = append(sampled, j)
+	}
+
+	go func() {
+		for _, j := range sampled {
+			if j.Jar == nil {
+				j.Jar = b.Jar
+			}
+			if j.Limiter == nil {
+				j.Limiter = b.Limiter
+			}
+			if j.OnChallenge == nil {
+				j.OnChallenge = b.OnChallenge
+			}
+			if j.Storm == nil {
+				j.Storm = b.Storm
+			}
+			if j.Breaker == nil {
+				j.Breaker = b.Breaker
+			}
+			if j.Dedup == nil {
+				j.Dedup = b.Dedup
+			}
+			if j.Cache == nil {
+				j.Cache = b.Cache
+			}
+			if j.RetryStatus == nil {
+				j.RetryStatus = b.RetryStatus
+			}
+			if j.TerminalStatus == nil {
+				j.TerminalStatus = b.TerminalStatus
+			}
+			if !b.Config.Get().apply(j) {
+				j.Skipped = "host not allowed by config"
+				b.track(j, "")
+				done <- j
+				continue
+			}
+			if b.reserveHost(j) {
+				queue <- j
+			}
+		}
+		close(queue)
+		wg.Wait()
+		b.finished = time.Now()
+		close(done)
+	}()
+
+	return done
+}
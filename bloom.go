@@ -0,0 +1,122 @@
+package fetch
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// BloomFilter is a probabilistic set: no false negatives,
+// false positives at the rate it was sized for.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hashes
+}
+
+// NewBloomFilter sized for n entries at false positive rate fp, i.e. 0.01.
+// Ten million entries at 1% take 12 MB.
+func NewBloomFilter(n uint64, fp float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// hashes by double hashing of a 128 bit FNV
+func (b *BloomFilter) hashes(s string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(s))
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// Add reports whether s was probably present, and adds it.
+func (b *BloomFilter) Add(s string) bool {
+	h1, h2 := b.hashes(s)
+	present := true
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		word, bit := pos/64, uint64(1)<<(pos%64)
+		if b.bits[word]&bit == 0 {
+			present = false
+			b.bits[word] |= bit
+		}
+	}
+	return present
+}
+
+// Has reports whether s was probably added.
+func (b *BloomFilter) Has(s string) bool {
+	h1, h2 := b.hashes(s)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(uint64(1)<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomSeen checks an exact LRU of recent URLs before the bloom filter
+type bloomSeen struct {
+	mu     sync.Mutex
+	bloom  *BloomFilter
+	max    int
+	recent map[string]*list.Element
+	order  *list.List
+}
+
+// NewBloomSeen is a SeenSet of bounded memory for crawls of tens of millions of URLs.
+// It is sized for n URLs at false positive rate fp;
+// a false positive drops a URL never seen.
+// The recent URLs - up to lru of them - are additionally kept exactly;
+// they answer the re-discoveries of navigation links, the bulk of all links,
+// without touching the filter.
+func NewBloomSeen(n uint64, fp float64, lru int) SeenSet {
+	if lru < 1 {
+		lru = 100000
+	}
+	return &bloomSeen{
+		bloom:  NewBloomFilter(n, fp),
+		max:    lru,
+		recent: map[string]*list.Element{},
+		order:  list.New(),
+	}
+}
+
+func (s *bloomSeen) Add(u string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.recent[u]; ok {
+		s.order.MoveToFront(el)
+		return true
+	}
+	present := s.bloom.Add(u)
+	s.recent[u] = s.order.PushFront(u)
+	if s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.recent, oldest.Value.(string))
+	}
+	return present
+}
+
+func (s *bloomSeen) Has(u string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recent[u]; ok {
+		return true
+	}
+	return s.bloom.Has(u)
+}
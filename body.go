@@ -0,0 +1,37 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// readBody slurps the response body into bts -
+// or spills it into a file.
+func (f *Job) readBody(resp *http.Response) error {
+
+	r, err := f.decodedBody(resp)
+	if err != nil {
+		return err
+	}
+	max := f.maxBytes()
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
+	}
+
+	if f.SpillDir != "" && (resp.ContentLength < 0 || resp.ContentLength > f.SpillThreshold || f.decoding != "") {
+		return f.spill(r)
+	}
+
+	bts, err := ioutil.ReadAll(r)
+	f.bts = bts
+	if err != nil {
+		return err
+	}
+	if max > 0 && int64(len(f.bts)) > max {
+		f.bts = f.bts[:max]
+		return fmt.Errorf("%w: more than %v bytes", ErrBodyTooLarge, max)
+	}
+	return nil
+}
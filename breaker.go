@@ -0,0 +1,222 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitState of one host
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open" // one probe is let through
+)
+
+// Breaker opens the circuit to a host after consecutive failures;
+// fetches then fail fast with ErrCircuitOpen, instead of each one
+// wasting its timeout against a dead host.
+// After Cooldown a single probe is let through;
+// its success closes the circuit, its failure opens it again.
+// Failures are network errors and 5xx responses.
+// Share one instance across jobs; Batch.Breaker does so.
+type Breaker struct {
+	Failures int           // consecutive failures opening the circuit; default 5
+	Cooldown time.Duration // default 30 seconds
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+type circuit struct {
+	fails   int
+	until   time.Time // open until
+	probing bool
+}
+
+// NewBreaker opens after failures, for cooldown.
+func NewBreaker(failures int, cooldown time.Duration) *Breaker {
+	return &Breaker{Failures: failures, Cooldown: cooldown}
+}
+
+func (b *Breaker) params() (int, time.Duration) {
+	n, cd := b.Failures, b.Cooldown
+	if n < 1 {
+		n = 5
+	}
+	if cd <= 0 {
+		cd = 30 * time.Second
+	}
+	return n, cd
+}
+
+func (b *Breaker) circuit(host string) *circuit {
+	if b.hosts == nil {
+		b.hosts = map[string]*circuit{}
+	}
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	return c
+}
+
+// allow returns ErrCircuitOpen - or nil;
+// probe is true, if the caller was admitted as the half-open probe.
+func (b *Breaker) allow(host string) (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	if c.until.IsZero() {
+		return false, nil
+	}
+	if time.Now().Before(c.until) || c.probing {
+		return false, fmt.Errorf("%w for %v after %v failures", ErrCircuitOpen, host, c.fails)
+	}
+	c.probing = true
+	return true, nil
+}
+
+// record the outcome of an attempt against host;
+// probe releases the half-open slot taken by allow.
+func (b *Breaker) record(host string, probe, failed, cancelled bool) {
+	n, cd := b.params()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	if probe {
+		c.probing = false
+	}
+	switch {
+	case cancelled:
+		// neither; a cancelled probe makes room for the next
+	case !failed:
+		c.fails, c.until = 0, time.Time{}
+	default:
+		c.fails++
+		if probe || c.fails >= n {
+			c.until = time.Now().Add(cd)
+		}
+	}
+}
+
+// State of the circuit to host.
+func (b *Breaker) State(host string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.hosts[host]
+	switch {
+	case !ok || c.until.IsZero():
+		return CircuitClosed
+	case time.Now().Before(c.until):
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+// checkBreaker admits an attempt; the admitted host is remembered,
+// since retries or mirrors may change the target before recordBreaker.
+func (f *Job) checkBreaker() error {
+	f.breakerHost, f.breakerProbe = "", false
+	if f.Breaker == nil {
+		return nil
+	}
+	host := targetHost(f)
+	probe, err := f.Breaker.allow(host)
+	if err != nil {
+		return err
+	}
+	f.breakerHost, f.breakerProbe = host, probe
+	return nil
+}
+
+// recordBreaker - called after each attempt,
+// so that a probe is settled before the next attempt asks again.
+// Attempts not admitted by checkBreaker - cache hits, dry runs,
+// circuit-open failures - are not recorded.
+func (f *Job) recordBreaker() {
+	if f.breakerHost == "" {
+		return
+	}
+	host, probe := f.breakerHost, f.breakerProbe
+	f.breakerHost, f.breakerProbe = "", false
+	err := classify(f.Err)
+	failed := f.Status >= 500
+	switch kindOf(err, f.Status) {
+	case KindDNS, KindConnect, KindTLS, KindTimeout, KindBodyRead:
+		failed = true
+	}
+	if f.Skipped != "" {
+		failed = false
+	}
+	f.Breaker.record(host, probe, failed, errors.Is(err, context.Canceled))
+}
+
+// BreakerState is the persisted state of a Breaker.
+type BreakerState struct {
+	Hosts map[string]CircuitSnapshot
+}
+
+// CircuitSnapshot is the persisted state of one host of a Breaker.
+type CircuitSnapshot struct {
+	Failures  int
+	OpenUntil time.Time `json:",omitempty"`
+}
+
+// Snapshot captures hosts with failures.
+func (b *Breaker) Snapshot() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerState{Hosts: map[string]CircuitSnapshot{}}
+	for host, c := range b.hosts {
+		if c.fails > 0 {
+			st.Hosts[host] = CircuitSnapshot{Failures: c.fails, OpenUntil: c.until}
+		}
+	}
+	return st
+}
+
+// Restore applies a snapshot; circuits open at the time of saving
+// and since expired come back half-open.
+func (b *Breaker) Restore(st BreakerState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for host, cs := range st.Hosts {
+		c := b.circuit(host)
+		c.fails, c.until, c.probing = cs.Failures, cs.OpenUntil, false
+	}
+}
+
+const breakerKey = "fetch-breaker.json"
+
+// SaveBreaker persists b into s.
+func SaveBreaker(s StateStore, b *Breaker) error {
+	bts, err := json.Marshal(b.Snapshot())
+	if err != nil {
+		return err
+	}
+	return s.Save(breakerKey, bts)
+}
+
+// LoadBreaker restores b from s; absent state is no error.
+func LoadBreaker(s StateStore, b *Breaker) error {
+	bts, err := s.Load(breakerKey)
+	if err != nil || bts == nil {
+		return err
+	}
+	st := BreakerState{}
+	if err := json.Unmarshal(bts, &st); err != nil {
+		return err
+	}
+	b.Restore(st)
+	return nil
+}
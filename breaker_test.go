@@ -0,0 +1,58 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A probe that fails and is retried must not leave the circuit
+// stuck half-open; the next cooldown must admit a new probe.
+func TestBreakerProbeRetried(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	host := u.Hostname()
+
+	br := NewBreaker(1, 50*time.Millisecond)
+	fetch := func() *Job {
+		j := &Job{URL: srv.URL, Breaker: br, MaxAttempts: 3, BackoffBase: time.Millisecond}
+		j.Fetch()
+		return j
+	}
+
+	fetch()
+	if st := br.State(host); st != CircuitOpen {
+		t.Fatalf("after failure: state %v, want open", st)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	j := fetch() // the probe fails; its retry hits the reopened circuit
+	if j.Kind != KindCircuit {
+		t.Fatalf("probe retry: kind %q, want %q; err %v", j.Kind, KindCircuit, j.Err)
+	}
+	if st := br.State(host); st != CircuitOpen {
+		t.Fatalf("after failed probe: state %v, want open", st)
+	}
+
+	fail.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	j = fetch()
+	if j.Err != nil || j.Status != http.StatusOK {
+		t.Fatalf("second probe: status %v, err %v", j.Status, j.Err)
+	}
+	if st := br.State(host); st != CircuitClosed {
+		t.Fatalf("after successful probe: state %v, want closed", st)
+	}
+}
@@ -0,0 +1,300 @@
+package fetch
+
+import (
+	"container/list"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response stored in a Cache.
+type CachedResponse struct {
+	Status    int
+	Header    http.Header
+	Body      []byte
+	Requested time.Time
+	Received  time.Time
+}
+
+func (r *CachedResponse) size() int64 {
+	n := int64(len(r.Body))
+	for k, vals := range r.Header {
+		for _, v := range vals {
+			n += int64(len(k) + len(v))
+		}
+	}
+	return n
+}
+
+// Cache stores responses for Job.Cache, keyed by url and,
+// if the response has a Vary header, the request headers it names.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, r *CachedResponse)
+	Delete(key string)
+}
+
+// cacheKey is the url - and the values of the request headers named by Vary
+func cacheKey(req *http.Request, vary string) string {
+	key := req.URL.String()
+	if vary == "" {
+		return key
+	}
+	names := []string{}
+	for _, name := range strings.Split(vary, ",") {
+		if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key += "\n" + name + ":" + strings.Join(req.Header.Values(name), ",")
+	}
+	return key
+}
+
+func cacheDirective(h http.Header, name string) bool {
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == name || strings.HasPrefix(d, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupCache serves the request from Cache, if the stored response
+// is fresh - or stale within MaxStale. A stale response is revalidated;
+// f.cached keeps it for the 304.
+// NoCache skips the lookup, but still stores the response.
+func (f *Job) lookupCache() bool {
+	f.cached, f.FromCache, f.CacheMiss, f.Revalidated = nil, false, false, false
+	if f.Cache == nil || f.Req.Method != "GET" || cacheDirective(f.Req.Header, "no-store") {
+		return false
+	}
+	primary := cacheKey(f.Req, "")
+	r, ok := f.Cache.Get(primary)
+	if ok {
+		if vary := r.Header.Get("Vary"); vary != "" {
+			if strings.TrimSpace(vary) == "*" {
+				return false
+			}
+			r, ok = f.Cache.Get(cacheKey(f.Req, vary))
+		}
+	}
+	if !ok {
+		if f.OnlyIfCached {
+			f.cacheMiss()
+			return true
+		}
+		return false
+	}
+
+	shadow := &Job{Req: f.Req, RespHeader: r.Header, requested: r.Requested, received: r.Received}
+	lifetime, age := shadow.Freshness()
+	usable := age < lifetime
+	if !usable && f.MaxStale != 0 {
+		usable = f.MaxStale < 0 || age < lifetime+f.MaxStale
+	}
+	if cacheDirective(r.Header, "no-cache") || (age >= lifetime && cacheDirective(r.Header, "must-revalidate")) {
+		usable = false
+	}
+
+	if usable && !f.NoCache {
+		f.serveCached(r, age)
+		f.event("cache", "hit; age %v of %v", age.Round(time.Second), lifetime.Round(time.Second))
+		return true
+	}
+	if f.OnlyIfCached {
+		f.cacheMiss()
+		return true
+	}
+
+	// revalidate - unless the caller asked for a conditional request of their own
+	if f.IfNoneMatch == "" && f.IfModifiedSince.IsZero() {
+		f.cached = r
+		if etag := r.Header.Get("ETag"); etag != "" {
+			f.setAttemptHeader("If-None-Match", etag)
+		}
+		if lm := r.Header.Get("Last-Modified"); lm != "" {
+			f.setAttemptHeader("If-Modified-Since", lm)
+		}
+		f.event("cache", "stale; revalidating")
+	}
+	return false
+}
+
+// cacheMiss for OnlyIfCached - like an intermediary would answer
+func (f *Job) cacheMiss() {
+	f.Status = http.StatusGatewayTimeout
+	f.RespHeader = http.Header{}
+	f.bts = nil
+	f.CacheMiss = true
+	f.event("cache", "miss for only-if-cached")
+}
+
+func (f *Job) serveCached(r *CachedResponse, age time.Duration) {
+	f.Status = r.Status
+	f.RespHeader = r.Header.Clone()
+	f.RespHeader.Set("Age", strconv.FormatInt(int64(age.Seconds()), 10))
+	f.bts = append([]byte(nil), r.Body...)
+	f.BytesDecoded = int64(len(f.bts))
+	f.requested, f.received = r.Requested, r.Received
+	f.Mod = lastModified(f.RespHeader)
+	f.ETag = f.RespHeader.Get("ETag")
+	f.FromCache = true
+}
+
+var cacheableStatus = map[int]bool{200: true, 203: true, 204: true, 300: true, 301: true, 404: true, 405: true, 410: true, 414: true, 501: true}
+
+// mergeNotModified answers a 304 upon our revalidation
+// from the stored response, updated by the headers of the 304.
+func (f *Job) mergeNotModified() {
+	if f.Status != http.StatusNotModified || f.cached == nil {
+		return
+	}
+	merged := f.cached.Header.Clone()
+	for k, vals := range f.RespHeader {
+		merged[k] = vals
+	}
+	f.Status = f.cached.Status
+	f.RespHeader = merged
+	f.bts = append([]byte(nil), f.cached.Body...)
+	f.BytesDecoded = int64(len(f.bts))
+	f.Revalidated = true
+	f.event("cache", "revalidated")
+}
+
+// storeCache runs after the body was read and checked.
+// Unsafe methods invalidate the url.
+// With Vary, the full response is stored under the variant key;
+// the url key only records the Vary header, for lookupCache to build the variant key.
+func (f *Job) storeCache() {
+	if f.Cache == nil || f.Err != nil {
+		return
+	}
+	if f.Req.Method != "GET" && f.Req.Method != "HEAD" {
+		if f.Status < 400 {
+			f.Cache.Delete(cacheKey(f.Req, ""))
+		}
+		return
+	}
+	if f.Req.Method != "GET" || f.SpillPath != "" {
+		return // spilled bodies are not held in memory
+	}
+
+	if !cacheableStatus[f.Status] || cacheDirective(f.RespHeader, "no-store") || cacheDirective(f.Req.Header, "no-store") {
+		return
+	}
+	if f.Req.Header.Get("Authorization") != "" && !cacheDirective(f.RespHeader, "public") {
+		return // RFC 7234 section 3.2
+	}
+	lifetime, _ := f.Freshness()
+	if lifetime <= 0 && f.RespHeader.Get("ETag") == "" && f.RespHeader.Get("Last-Modified") == "" {
+		return // neither fresh nor revalidatable
+	}
+
+	r := &CachedResponse{
+		Status:    f.Status,
+		Header:    f.RespHeader.Clone(),
+		Body:      f.Scrubber.Scrub(append([]byte(nil), f.bts...)),
+		Requested: f.requested,
+		Received:  f.received,
+	}
+	r.Header.Del("Age")
+	vary := r.Header.Get("Vary")
+	if strings.TrimSpace(vary) == "*" {
+		return
+	}
+	if vary == "" {
+		f.Cache.Set(cacheKey(f.Req, ""), r)
+		return
+	}
+	f.Cache.Set(cacheKey(f.Req, ""), &CachedResponse{Header: http.Header{"Vary": {vary}}})
+	f.Cache.Set(cacheKey(f.Req, vary), r)
+}
+
+// local is true for results answered by the Cache alone;
+// no request went to the server.
+func (f *Job) local() bool {
+	return f.FromCache || f.CacheMiss
+}
+
+// MemoryCache is an in-memory Cache,
+// evicting the least recently used responses beyond MaxBytes.
+// MaxBytes <= 0 means no limit; the zero value is ready to use.
+type MemoryCache struct {
+	MaxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type memoryEntry struct {
+	key string
+	r   *CachedResponse
+}
+
+// NewMemoryCache holds up to maxBytes of bodies and headers.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{MaxBytes: maxBytes}
+}
+
+// init - mu must be held
+func (c *MemoryCache) init() {
+	if c.entries == nil {
+		c.entries, c.order = map[string]*list.Element{}, list.New()
+	}
+}
+
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*memoryEntry).r, true
+}
+
+// Set replaces the entry for key;
+// a response larger than MaxBytes is not stored at all.
+func (c *MemoryCache) Set(key string, r *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.remove(key)
+	size := r.size()
+	if c.MaxBytes > 0 && size > c.MaxBytes {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, r: r})
+	c.size += size
+	for c.MaxBytes > 0 && c.size > c.MaxBytes {
+		c.remove(c.order.Back().Value.(*memoryEntry).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.remove(key)
+}
+
+// remove - mu must be held
+func (c *MemoryCache) remove(key string) {
+	if el, ok := c.entries[key]; ok {
+		c.size -= el.Value.(*memoryEntry).r.size()
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
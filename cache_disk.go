@@ -0,0 +1,204 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DiskCache is a Cache in a directory, surviving between runs
+// of command line tools and batch crawlers.
+// Each response is a file - its JSON header line followed by the body -
+// written to a temp file and renamed, so that concurrent processes
+// never see partial entries.
+// Beyond MaxBytes, the least recently used files are removed.
+type DiskCache struct {
+	Dir      string
+	MaxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	files map[string]*list.Element // path => element of order
+	order *list.List               // of *diskEntry; most recently used first
+}
+
+type diskEntry struct {
+	path string
+	size int64
+}
+
+var (
+	diskCacheEntry = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	diskCacheTemp  = regexp.MustCompile(`^[0-9a-f]{64}\.[0-9]+\.tmp$`)
+)
+
+// diskCacheTempAge - younger temp files may belong to a concurrent writer
+const diskCacheTempAge = time.Hour
+
+// NewDiskCache creates dir if need be, and indexes existing entries.
+// Only files named like ours - xx/<64 hex digits> - are touched;
+// our temp files are removed when older than an hour.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	c := &DiskCache{Dir: dir, MaxBytes: maxBytes}
+	shards, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type found struct {
+		path string
+		info os.FileInfo
+	}
+	entries := []found{}
+	for _, shard := range shards {
+		if !shard.IsDir() || len(shard.Name()) != 2 {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(dir, shard.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range files {
+			name := info.Name()
+			if info.IsDir() || len(name) < 2 || name[:2] != shard.Name() {
+				continue
+			}
+			path := filepath.Join(dir, shard.Name(), name)
+			switch {
+			case diskCacheEntry.MatchString(name):
+				entries = append(entries, found{path, info})
+			case diskCacheTemp.MatchString(name) && time.Since(info.ModTime()) > diskCacheTempAge:
+				os.Remove(path) // left over by a crash
+			}
+		}
+	}
+	sort.Slice(entries, func(i, k int) bool { return entries[i].info.ModTime().After(entries[k].info.ModTime()) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	for _, e := range entries {
+		c.files[e.path] = c.order.PushBack(&diskEntry{path: e.path, size: e.info.Size()})
+		c.size += e.info.Size()
+	}
+	c.evict()
+	return c, nil
+}
+
+// init - mu must be held
+func (c *DiskCache) init() {
+	if c.files == nil {
+		c.files, c.order = map[string]*list.Element{}, list.New()
+	}
+}
+
+// path shards by the first two hex digits
+func (c *DiskCache) path(key string) string {
+	name := cacheItemKey("", key)
+	return filepath.Join(c.Dir, name[:2], name)
+}
+
+func (c *DiskCache) Get(key string) (*CachedResponse, bool) {
+	path := c.path(key)
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	i := bytes.IndexByte(bts, '\n')
+	if i < 0 {
+		return nil, false
+	}
+	r := &CachedResponse{}
+	if err := json.Unmarshal(bts[:i], r); err != nil {
+		return nil, false
+	}
+	r.Body = bts[i+1:]
+
+	now := time.Now()
+	os.Chtimes(path, now, now) // for eviction in later runs
+	c.mu.Lock()
+	c.init()
+	if el, ok := c.files[path]; ok {
+		c.order.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	return r, true
+}
+
+func (c *DiskCache) Set(key string, r *CachedResponse) {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	hdr := *r
+	hdr.Body = nil
+	meta, err := json.Marshal(hdr)
+	if err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	w.Write(meta)
+	w.WriteByte('\n')
+	w.Write(r.Body)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.forget(path)
+	size := int64(len(meta) + 1 + len(r.Body))
+	c.files[path] = c.order.PushFront(&diskEntry{path: path, size: size})
+	c.size += size
+	c.evict()
+}
+
+func (c *DiskCache) Delete(key string) {
+	path := c.path(key)
+	os.Remove(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.forget(path)
+}
+
+// forget drops path from the index; mu must be held
+func (c *DiskCache) forget(path string) {
+	if el, ok := c.files[path]; ok {
+		c.size -= el.Value.(*diskEntry).size
+		c.order.Remove(el)
+		delete(c.files, path)
+	}
+}
+
+// evict the least recently used files beyond MaxBytes; mu must be held
+func (c *DiskCache) evict() {
+	for c.MaxBytes > 0 && c.size > c.MaxBytes {
+		path := c.order.Back().Value.(*diskEntry).path
+		os.Remove(path)
+		c.forget(path)
+	}
+}
@@ -0,0 +1,72 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/memcache"
+)
+
+// cacheItemKey fits any url and Vary combination
+// into the key limits of memcache (250 bytes) and redis.
+func cacheItemKey(prefix, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return prefix + hex.EncodeToString(sum[:])
+}
+
+// MemcacheCache shares cached responses across App Engine instances.
+// Create one per request, since the appengine context is.
+// Responses beyond the memcache item limit of 1 MB are not cached.
+// Errors are dropped; a failing cache is an empty cache.
+type MemcacheCache struct {
+	Ctx        context.Context // appengine.NewContext(r)
+	Prefix     string          // default "fetch:"
+	Expiration time.Duration   // default 24 hours; stale responses are kept for revalidation
+}
+
+// NewMemcacheCache with defaults.
+func NewMemcacheCache(ctx context.Context) *MemcacheCache {
+	return &MemcacheCache{Ctx: ctx}
+}
+
+func (c *MemcacheCache) key(key string) string {
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = "fetch:"
+	}
+	return cacheItemKey(prefix, key)
+}
+
+func (c *MemcacheCache) expiration() time.Duration {
+	if c.Expiration <= 0 {
+		return 24 * time.Hour
+	}
+	return c.Expiration
+}
+
+func (c *MemcacheCache) Get(key string) (*CachedResponse, bool) {
+	item, err := memcache.Get(c.Ctx, c.key(key))
+	if err != nil {
+		return nil, false
+	}
+	r := &CachedResponse{}
+	if err := json.Unmarshal(item.Value, r); err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+func (c *MemcacheCache) Set(key string, r *CachedResponse) {
+	bts, err := json.Marshal(r)
+	if err != nil || len(bts) > 1<<20-1024 {
+		return
+	}
+	memcache.Set(c.Ctx, &memcache.Item{Key: c.key(key), Value: bts, Expiration: c.expiration()})
+}
+
+func (c *MemcacheCache) Delete(key string) {
+	memcache.Delete(c.Ctx, c.key(key))
+}
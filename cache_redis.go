@@ -0,0 +1,76 @@
+//go:build redis
+
+package fetch
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+// RedisCache shares cached responses across instances outside of App Engine,
+// i.e. via Cloud Memorystore. Build with -tags redis.
+// Errors are dropped; a failing cache is an empty cache.
+type RedisCache struct {
+	Client     redis.UniversalClient
+	Prefix     string        // default "fetch:"
+	Expiration time.Duration // default 24 hours; stale responses are kept for revalidation
+	Timeout    time.Duration // per operation; default 500ms
+}
+
+// NewRedisCache with defaults.
+func NewRedisCache(client redis.UniversalClient) *RedisCache {
+	return &RedisCache{Client: client}
+}
+
+func (c *RedisCache) key(key string) string {
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = "fetch:"
+	}
+	return cacheItemKey(prefix, key)
+}
+
+func (c *RedisCache) ctx() (context.Context, context.CancelFunc) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 500 * time.Millisecond
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func (c *RedisCache) Get(key string) (*CachedResponse, bool) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	bts, err := c.Client.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	r := &CachedResponse{}
+	if err := json.Unmarshal(bts, r); err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+func (c *RedisCache) Set(key string, r *CachedResponse) {
+	bts, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	expiration := c.Expiration
+	if expiration <= 0 {
+		expiration = 24 * time.Hour
+	}
+	ctx, cancel := c.ctx()
+	defer cancel()
+	c.Client.Set(ctx, c.key(key), bts, expiration)
+}
+
+func (c *RedisCache) Delete(key string) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	c.Client.Del(ctx, c.key(key))
+}
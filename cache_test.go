@@ -0,0 +1,149 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheFreshness(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("fresh"))
+	}))
+	defer srv.Close()
+
+	c := &MemoryCache{} // the zero value must do
+	for i := 0; i < 2; i++ {
+		j := &Job{URL: srv.URL, Cache: c}
+		if err := j.Do(); err != nil {
+			t.Fatal(err)
+		}
+		if string(j.Bytes()) != "fresh" {
+			t.Fatalf("fetch %v: body %q", i, j.Bytes())
+		}
+		if j.FromCache != (i == 1) {
+			t.Errorf("fetch %v: FromCache %v", i, j.FromCache)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server hit %v times, want 1", n)
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	c := NewMemoryCache(1 << 20)
+	fetch := func(lang string) *Job {
+		j := &Job{URL: srv.URL, Cache: c, Headers: http.Header{"Accept-Language": {lang}}}
+		if err := j.Do(); err != nil {
+			t.Fatal(err)
+		}
+		if string(j.Bytes()) != lang {
+			t.Fatalf("%v: body %q", lang, j.Bytes())
+		}
+		return j
+	}
+	fetch("de")
+	fetch("en")
+	if !fetch("de").FromCache || !fetch("en").FromCache {
+		t.Error("variants not served from the cache")
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server hit %v times, want 2", n)
+	}
+}
+
+func TestCacheNotModified(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("X-Revalidated", "yes")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	c := NewMemoryCache(1 << 20)
+	(&Job{URL: srv.URL, Cache: c}).Fetch()
+	j := &Job{URL: srv.URL, Cache: c}
+	if err := j.Do(); err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != http.StatusOK || string(j.Bytes()) != "body" {
+		t.Fatalf("status %v, body %q", j.Status, j.Bytes())
+	}
+	if !j.Revalidated || j.FromCache {
+		t.Errorf("Revalidated %v, FromCache %v", j.Revalidated, j.FromCache)
+	}
+	if j.RespHeader.Get("X-Revalidated") != "yes" || j.RespHeader.Get("ETag") != `"v1"` {
+		t.Errorf("headers not merged: %v", j.RespHeader)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server hit %v times, want 2", n)
+	}
+}
+
+func TestMemoryCacheSize(t *testing.T) {
+	c := NewMemoryCache(10)
+	c.Set("big", &CachedResponse{Body: make([]byte, 11)})
+	if _, ok := c.Get("big"); ok {
+		t.Error("entry beyond MaxBytes was stored")
+	}
+	c.Set("a", &CachedResponse{Body: make([]byte, 6)})
+	c.Set("a", &CachedResponse{Body: make([]byte, 6)})
+	c.Set("b", &CachedResponse{Body: make([]byte, 4)})
+	if _, ok := c.Get("a"); !ok {
+		t.Error("replacing an entry counted it twice")
+	}
+	c.Set("c", &CachedResponse{Body: make([]byte, 4)})
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry not evicted")
+	}
+}
+
+// Validators of a revalidation must not outlive the attempt;
+// once the entry is gone, a refetch of the same job gets the full body.
+func TestCacheValidatorsPerAttempt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	c := NewMemoryCache(1 << 20)
+	j := &Job{URL: srv.URL, Cache: c}
+	j.Fetch()
+	j.Fetch()
+	if !j.Revalidated {
+		t.Fatalf("second fetch not revalidated: status %v", j.Status)
+	}
+	c.Delete(cacheKey(j.Req, ""))
+	if err := j.Do(); err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != http.StatusOK || string(j.Bytes()) != "body" {
+		t.Errorf("after eviction: status %v, body %q", j.Status, j.Bytes())
+	}
+}
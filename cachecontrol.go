@@ -0,0 +1,46 @@
+package fetch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cacheDirectives returns the request Cache-Control directives
+// for NoCache, OnlyIfCached and MaxStale.
+func (f *Job) cacheDirectives() []string {
+	dirs := []string{}
+	if f.NoCache {
+		dirs = append(dirs, "no-cache")
+	}
+	if f.OnlyIfCached {
+		dirs = append(dirs, "only-if-cached")
+	}
+	if f.MaxStale < 0 {
+		dirs = append(dirs, "max-stale")
+	} else if f.MaxStale > 0 {
+		dirs = append(dirs, fmt.Sprintf("max-stale=%d", int64(f.MaxStale.Seconds())))
+	}
+	return dirs
+}
+
+// setCacheControl adds the directives to the request,
+// keeping any Cache-Control set by the caller.
+func (f *Job) setCacheControl() {
+	dirs := f.cacheDirectives()
+	if len(dirs) == 0 {
+		return
+	}
+	if cc := f.Req.Header.Get("Cache-Control"); cc != "" {
+		for _, d := range dirs {
+			if !strings.Contains(cc, strings.SplitN(d, "=", 2)[0]) {
+				cc += ", " + d
+			}
+		}
+		f.Req.Header.Set("Cache-Control", cc)
+		return
+	}
+	f.Req.Header.Set("Cache-Control", strings.Join(dirs, ", "))
+	if f.NoCache {
+		f.Req.Header.Set("Pragma", "no-cache") // http/1.0 caches
+	}
+}
@@ -0,0 +1,94 @@
+package fetch
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CDNInfo is distilled from CDN debug headers,
+// for analyzing cache behavior and edge routing across a batch.
+type CDNInfo struct {
+	Provider string   // cloudflare, fastly, cloudfront, akamai or empty
+	Edge     string   // point of presence, i.e. "FRA"
+	RayID    string   // cloudflare request id
+	Cache    string   // raw cache status, i.e. "HIT" or "MISS, HIT"
+	Hit      bool     // the edge closest to us served from cache
+	ServedBy []string // cache nodes, closest last
+	Age      time.Duration
+	HasAge   bool
+}
+
+func parseCDN(h http.Header) *CDNInfo {
+
+	c := &CDNInfo{}
+	found := false
+
+	if ray := h.Get("Cf-Ray"); ray != "" {
+		found = true
+		c.Provider = "cloudflare"
+		c.RayID = ray
+		if pos := strings.LastIndex(ray, "-"); pos > -1 {
+			c.Edge = ray[pos+1:]
+		}
+		c.Cache = h.Get("Cf-Cache-Status")
+	}
+
+	if sb := h.Get("X-Served-By"); sb != "" {
+		found = true
+		if c.Provider == "" {
+			c.Provider = "fastly"
+		}
+		for _, node := range strings.Split(sb, ",") {
+			c.ServedBy = append(c.ServedBy, strings.TrimSpace(node))
+		}
+		last := c.ServedBy[len(c.ServedBy)-1]
+		if pos := strings.LastIndex(last, "-"); pos > -1 && c.Edge == "" {
+			c.Edge = last[pos+1:]
+		}
+	}
+
+	if pop := h.Get("X-Amz-Cf-Pop"); pop != "" {
+		found = true
+		c.Provider = "cloudfront"
+		c.Edge = pop
+	}
+
+	if strings.HasPrefix(h.Get("Server"), "AkamaiGHost") || h.Get("X-Akamai-Request-Id") != "" {
+		found = true
+		if c.Provider == "" {
+			c.Provider = "akamai"
+		}
+	}
+
+	if xc := h.Get("X-Cache"); xc != "" {
+		found = true
+		if c.Cache == "" {
+			c.Cache = xc
+		}
+		if strings.Contains(strings.ToLower(xc), "cloudfront") {
+			c.Provider = "cloudfront"
+		}
+	}
+
+	if c.Cache != "" {
+		// fastly lists from origin shield to edge, the edge comes last
+		parts := strings.Split(c.Cache, ",")
+		last := strings.ToUpper(strings.TrimSpace(parts[len(parts)-1]))
+		c.Hit = strings.HasPrefix(last, "HIT") || strings.HasPrefix(last, "TCP_HIT") || last == "REVALIDATED"
+	}
+
+	if age := h.Get("Age"); age != "" {
+		if secs, err := strconv.Atoi(strings.TrimSpace(age)); err == nil && secs >= 0 {
+			found = true
+			c.HasAge = true
+			c.Age = time.Duration(secs) * time.Second
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return c
+}
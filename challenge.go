@@ -0,0 +1,84 @@
+package fetch
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Challenge is an anti-bot challenge or captcha,
+// detected in the response of Job.
+type Challenge struct {
+	Job  *Job
+	Host string
+	Kind string // name of the matching fingerprint
+}
+
+// ChallengeHandler reacts to a challenge before the fetch is retried -
+// solving it externally and setting the resulting cookie,
+// switching Job.Proxy, or backing off the host by returning a long wait.
+// Returning false gives up; the challenge response remains the result.
+type ChallengeHandler func(ctx context.Context, c Challenge) (retry bool, wait time.Duration)
+
+// ChallengeFingerprints detect challenge pages
+// served with 403, 429 or 503.
+var ChallengeFingerprints = []Fingerprint{
+	{Name: "cloudflare", Class: "challenge", Header: "Cf-Mitigated", HeaderMatch: regexp.MustCompile(`(?i)challenge`)},
+	{Name: "cloudflare", Class: "challenge", Body: regexp.MustCompile(`(?i)/cdn-cgi/challenge-platform/|cf-chl-|Attention Required! \| Cloudflare`)},
+	{Name: "datadome", Class: "challenge", Body: regexp.MustCompile(`(?i)captcha-delivery\.com`)},
+	{Name: "perimeterx", Class: "challenge", Body: regexp.MustCompile(`(?i)px-captcha|_pxCaptcha`)},
+	{Name: "akamai", Class: "challenge", Body: regexp.MustCompile(`(?i)<title>Access Denied</title>[\s\S]{0,1000}Reference #`)},
+	{Name: "captcha", Class: "challenge", Body: regexp.MustCompile(`(?i)class="(g-recaptcha|h-captcha)"`)},
+}
+
+// detectChallenge - soft errors of class challenge on 2xx,
+// or ChallengeFingerprints on 403, 429 and 503.
+func (f *Job) detectChallenge() (string, bool) {
+	var se *SoftError
+	if errors.As(f.Err, &se) && se.Class == "challenge" {
+		return se.Fingerprint, true
+	}
+	if f.Err != nil {
+		return "", false
+	}
+	switch f.Status {
+	case 403, 429, 503:
+	default:
+		return "", false
+	}
+	head := f.bts
+	if len(head) > 64<<10 {
+		head = head[:64<<10]
+	}
+	for _, fp := range ChallengeFingerprints {
+		if fp.match(f, head) {
+			return fp.Name, true
+		}
+	}
+	return "", false
+}
+
+// challengeAttempt lets OnChallenge decide on another attempt.
+// At least one retry is granted, regardless of MaxAttempts.
+func (f *Job) challengeAttempt(ctx context.Context) (wait time.Duration, handled, retry bool) {
+	if f.OnChallenge == nil || ctx.Err() != nil {
+		return 0, false, false
+	}
+	kind, ok := f.detectChallenge()
+	if !ok {
+		return 0, false, false
+	}
+	f.event("challenge", "%v challenge with status %v", kind, f.Status)
+	if f.Attempts >= f.MaxAttempts && f.Attempts > 1 {
+		return 0, true, false
+	}
+	retry, wait = f.OnChallenge(ctx, Challenge{Job: f, Host: f.Req.URL.Hostname(), Kind: kind})
+	if !retry || !f.rewindBody() {
+		return 0, true, false
+	}
+	f.event("challenge", "handled; retrying in %v", wait)
+	f.resetResponse()
+	return wait, true, true
+}
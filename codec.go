@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Codec decodes response bodies; RPCCodecs are Codecs.
+type Codec interface {
+	Unmarshal(bts []byte, v interface{}) error
+}
+
+// xmlCodec converts non UTF-8 documents; see FetchXML.
+type xmlCodec struct{}
+
+func (xmlCodec) Unmarshal(bts []byte, v interface{}) error {
+	return newXMLDecoder(bts).Decode(v)
+}
+
+// ErrNoCodec is returned by Decode for unregistered content types.
+var ErrNoCodec = errors.New("no codec for content type")
+
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{m: map[string]Codec{
+	"application/json":                JSONCodec{},
+	"text/json":                       JSONCodec{},
+	"application/xml":                 xmlCodec{},
+	"text/xml":                        xmlCodec{},
+	"application/x-protobuf":          ProtoCodec{},
+	"application/protobuf":            ProtoCodec{},
+	"application/vnd.google.protobuf": ProtoCodec{},
+}}
+
+// RegisterCodec adds or replaces the codec for a content type,
+// i.e. for proprietary "application/vnd.acme.ledger".
+// Structured syntax suffixes such as "+json" are registered as such.
+func RegisterCodec(contentType string, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.m[strings.ToLower(contentType)] = c
+}
+
+// codecFor finds the codec for a media type,
+// falling back to its structured syntax suffix:
+// "application/ld+json" => "+json" => "application/json".
+func codecFor(ct string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	ct = strings.ToLower(ct)
+	if c, ok := codecs.m[ct]; ok {
+		return c, true
+	}
+	if i := strings.LastIndexByte(ct, '+'); i > 0 {
+		suffix := ct[i:]
+		if c, ok := codecs.m[suffix]; ok {
+			return c, true
+		}
+		if c, ok := codecs.m["application/"+suffix[1:]]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Decode unmarshals the body of a fetched job into v,
+// picking the codec by content type - declared or sniffed.
+// The status must be 2xx.
+func (j *Job) Decode(v interface{}) error {
+	if j.Err != nil {
+		return j.Err
+	}
+	if j.Status < 200 || j.Status > 299 {
+		return j.StatusErr()
+	}
+	ct := j.ContentType()
+	c, ok := codecFor(ct)
+	if !ok && j.SniffedType != "" {
+		c, ok = codecFor(j.SniffedType)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q for %v", ErrNoCodec, ct, j.URL)
+	}
+	bts := j.bts
+	if j.stream != nil {
+		body := j.Body()
+		defer body.Close()
+		var err error
+		bts, err = ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+	}
+	return c.Unmarshal(bts, v)
+}
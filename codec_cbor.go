@@ -0,0 +1,19 @@
+//go:build cbor
+
+package fetch
+
+import (
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBORCodec is compiled in with -tags cbor.
+type CBORCodec struct{}
+
+func (CBORCodec) Unmarshal(bts []byte, v interface{}) error {
+	return cbor.Unmarshal(bts, v)
+}
+
+func init() {
+	RegisterCodec("application/cbor", CBORCodec{})
+	RegisterCodec("+cbor", CBORCodec{}) // i.e. application/senml+cbor
+}
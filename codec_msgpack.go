@@ -0,0 +1,20 @@
+//go:build msgpack
+
+package fetch
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPackCodec is compiled in with -tags msgpack.
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) Unmarshal(bts []byte, v interface{}) error {
+	return msgpack.Unmarshal(bts, v)
+}
+
+func init() {
+	RegisterCodec("application/msgpack", MsgPackCodec{})
+	RegisterCodec("application/x-msgpack", MsgPackCodec{})
+	RegisterCodec("application/vnd.msgpack", MsgPackCodec{})
+}
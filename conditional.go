@@ -0,0 +1,51 @@
+package fetch
+
+import (
+	"net/http"
+	"time"
+)
+
+// Revalidate makes j a conditional GET
+// against the validators of a previous fetch of the same url.
+func (j *Job) Revalidate(prev *Job) {
+	j.IfModifiedSince = prev.Mod
+	j.IfNoneMatch = prev.ETag
+}
+
+// WithConditional sends If-Modified-Since and If-None-Match;
+// zero values are omitted.
+func WithConditional(mod time.Time, etag string) Option {
+	return func(j *Job) {
+		j.IfModifiedSince = mod
+		j.IfNoneMatch = etag
+	}
+}
+
+// setConditional adds the validators to the request.
+// Values set on a prebuilt request take precedence.
+func (f *Job) setConditional() {
+	if !f.IfModifiedSince.IsZero() && f.Req.Header.Get("If-Modified-Since") == "" {
+		f.Req.Header.Set("If-Modified-Since", f.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if f.IfNoneMatch != "" && f.Req.Header.Get("If-None-Match") == "" {
+		f.Req.Header.Set("If-None-Match", f.IfNoneMatch)
+	}
+}
+
+// checkNotModified records the validators of the response.
+// A 304 carries no body; Mod and ETag keep the values we sent,
+// unless the server repeated them.
+func (f *Job) checkNotModified(h http.Header) {
+	f.ETag = h.Get("ETag")
+	f.NotModified = f.Status == http.StatusNotModified
+	if !f.NotModified {
+		return
+	}
+	if f.ETag == "" {
+		f.ETag = f.IfNoneMatch
+	}
+	if f.Mod.IsZero() {
+		f.Mod = f.IfModifiedSince
+	}
+	f.event("conditional", "not modified")
+}
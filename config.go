@@ -0,0 +1,194 @@
+package fetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Config holds the settings of a long running fetch service,
+// which may change at runtime. Zero values leave jobs unchanged.
+type Config struct {
+	RPS   float64              `json:",omitempty"` // default rate of the Limiter; zero keeps the current one
+	Burst int                  `json:",omitempty"`
+	Hosts map[string]HostLimit `json:",omitempty"` // per host rates; only RPS and Burst are used
+
+	// Allow and Deny hold host patterns as in path.Match, i.e. "*.example.com".
+	// Empty Allow allows all hosts; Deny wins.
+	Allow []string `json:",omitempty"`
+	Deny  []string `json:",omitempty"`
+
+	MaxAttempts    int   `json:",omitempty"`
+	RetryStatus    []int `json:",omitempty"`
+	TerminalStatus []int `json:",omitempty"`
+
+	Flags map[string]bool `json:",omitempty"` // feature flags for the service code
+}
+
+// Validate rejects configs, which would otherwise fail at fetch time.
+func (c *Config) Validate() error {
+	if c.RPS < 0 || c.Burst < 0 || c.MaxAttempts < 0 {
+		return fmt.Errorf("config: negative RPS, Burst or MaxAttempts")
+	}
+	for host, hl := range c.Hosts {
+		if hl.RPS < 0 || hl.Burst < 0 {
+			return fmt.Errorf("config: negative rate for host %v", host)
+		}
+	}
+	for _, pattern := range append(append([]string{}, c.Allow...), c.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("config: host pattern %q: %w", pattern, err)
+		}
+	}
+	for _, code := range append(append([]int{}, c.RetryStatus...), c.TerminalStatus...) {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("config: invalid status code %v", code)
+		}
+	}
+	return nil
+}
+
+// Flag is false for unknown flags and for a nil Config.
+func (c *Config) Flag(name string) bool {
+	return c != nil && c.Flags[name]
+}
+
+// Allowed checks host against Allow and Deny.
+func (c *Config) Allowed(host string) bool {
+	if c == nil {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range c.Deny {
+		if ok, _ := path.Match(pattern, host); ok {
+			return false
+		}
+	}
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range c.Allow {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// apply the retry settings to j; false if the host is not allowed
+func (c *Config) apply(j *Job) bool {
+	if c == nil {
+		return true
+	}
+	if !c.Allowed(targetHost(j)) {
+		return false
+	}
+	if c.MaxAttempts > 0 {
+		j.MaxAttempts = c.MaxAttempts
+	}
+	if c.RetryStatus != nil {
+		j.RetryStatus = c.RetryStatus
+	}
+	if c.TerminalStatus != nil {
+		j.TerminalStatus = c.TerminalStatus
+	}
+	return true
+}
+
+// ConfigSource delivers the current config, i.e. from a file or a database.
+type ConfigSource func() (*Config, error)
+
+// ConfigFile reads JSON from path.
+func ConfigFile(path string) ConfigSource {
+	return func() (*Config, error) {
+		bts, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c := &Config{}
+		if err := json.Unmarshal(bts, c); err != nil {
+			return nil, fmt.Errorf("config %v: %w", path, err)
+		}
+		return c, nil
+	}
+}
+
+// LiveConfig swaps configs atomically.
+// Jobs of a Batch see the config current at their dispatch.
+// An invalid config is rejected; the previous one stays in effect.
+type LiveConfig struct {
+	Source   ConfigSource
+	Limiter  *Limiter        // if set, receives the rates of each new config
+	Interval time.Duration   // Watch() polls the source; default 30 seconds
+	OnError  func(err error) // Watch() reload failures
+
+	cur atomic.Pointer[Config]
+}
+
+// NewLiveConfig loads the initial config; it must be valid.
+func NewLiveConfig(src ConfigSource, lim *Limiter) (*LiveConfig, error) {
+	lc := &LiveConfig{Source: src, Limiter: lim}
+	if err := lc.Reload(); err != nil {
+		return nil, err
+	}
+	return lc, nil
+}
+
+// Get returns the current config; do not modify it.
+func (lc *LiveConfig) Get() *Config {
+	if lc == nil {
+		return nil
+	}
+	return lc.cur.Load()
+}
+
+// Reload fetches, validates and swaps in the config of Source.
+func (lc *LiveConfig) Reload() error {
+	c, err := lc.Source()
+	if err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(c, lc.cur.Load()) {
+		return nil
+	}
+	if lc.Limiter != nil {
+		if c.RPS > 0 {
+			lc.Limiter.SetDefault(c.RPS, c.Burst)
+		}
+		for host, hl := range c.Hosts {
+			lc.Limiter.SetHost(host, hl.RPS, hl.Burst)
+		}
+	}
+	lc.cur.Store(c)
+	return nil
+}
+
+// Watch reloads every Interval until ctx is done.
+func (lc *LiveConfig) Watch(ctx context.Context) {
+	iv := lc.Interval
+	if iv <= 0 {
+		iv = 30 * time.Second
+	}
+	tick := time.NewTicker(iv)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := lc.Reload(); err != nil && lc.OnError != nil {
+				lc.OnError(err)
+			}
+		}
+	}
+}
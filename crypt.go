@@ -0,0 +1,210 @@
+package fetch
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeyProvider supplies AES keys of 16, 24 or 32 bytes.
+// The key id is stored in clear with the ciphertext,
+// so keys can be rotated without breaking older files.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+// StaticKey is a KeyProvider with a single key.
+type StaticKey struct {
+	ID  string
+	Val []byte
+}
+
+func (k StaticKey) CurrentKey() (string, []byte, error) { return k.ID, k.Val, nil }
+
+func (k StaticKey) Key(id string) ([]byte, error) {
+	if id != k.ID {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+	return k.Val, nil
+}
+
+var ErrCiphertext = errors.New("ciphertext corrupt or truncated")
+
+// The format is
+//
+//	magic | key id length | key id | 7 bytes nonce prefix | segments
+//
+// with segments
+//
+//	uint32 length | sealed chunk
+//
+// The nonce of each chunk is prefix | uint32 counter | last flag,
+// thus chunks can neither be reordered nor cut off.
+const (
+	cryptMagic = "FENC1"
+	cryptChunk = 64 * 1024
+)
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	ctr    uint32
+	buf    []byte
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewEncryptWriter wraps w for AES-GCM encryption.
+// Close() must be called to write the final chunk;
+// it does not close w.
+func NewEncryptWriter(w io.Writer, kp KeyProvider) (io.WriteCloser, error) {
+	id, key, err := kp.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("key id too long")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	ew := &encryptWriter{w: w, aead: aead, prefix: make([]byte, 7)}
+	if _, err := rand.Read(ew.prefix); err != nil {
+		return nil, err
+	}
+	hdr := append([]byte(cryptMagic), byte(len(id)))
+	hdr = append(hdr, id...)
+	hdr = append(hdr, ew.prefix...)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
+
+func chunkNonce(prefix []byte, ctr uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[7:11], ctr)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func (ew *encryptWriter) seal(last bool) error {
+	sealed := ew.aead.Seal(nil, chunkNonce(ew.prefix, ew.ctr, last), ew.buf, nil)
+	ew.ctr++
+	ew.buf = ew.buf[:0]
+	lb := make([]byte, 4)
+	binary.BigEndian.PutUint32(lb, uint32(len(sealed)))
+	if _, err := ew.w.Write(lb); err != nil {
+		return err
+	}
+	_, err := ew.w.Write(sealed)
+	return err
+}
+
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		take := cryptChunk - len(ew.buf)
+		if take > len(p) {
+			take = len(p)
+		}
+		ew.buf = append(ew.buf, p[:take]...)
+		p = p[take:]
+		n += take
+		// keep a full chunk buffered, until we know whether it is the last
+		if len(ew.buf) == cryptChunk && len(p) > 0 {
+			if err := ew.seal(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (ew *encryptWriter) Close() error {
+	return ew.seal(true)
+}
+
+type decryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	ctr    uint32
+	buf    []byte
+	done   bool
+}
+
+// NewDecryptReader reverses NewEncryptWriter.
+func NewDecryptReader(r io.Reader, kp KeyProvider) (io.Reader, error) {
+	hdr := make([]byte, len(cryptMagic)+1)
+	if _, err := io.ReadFull(r, hdr); err != nil || string(hdr[:len(cryptMagic)]) != cryptMagic {
+		return nil, ErrCiphertext
+	}
+	id := make([]byte, hdr[len(cryptMagic)])
+	if _, err := io.ReadFull(r, id); err != nil {
+		return nil, ErrCiphertext
+	}
+	key, err := kp.Key(string(id))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	dr := &decryptReader{r: r, aead: aead, prefix: make([]byte, 7)}
+	if _, err := io.ReadFull(r, dr.prefix); err != nil {
+		return nil, ErrCiphertext
+	}
+	return dr, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.buf) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		lb := make([]byte, 4)
+		if _, err := io.ReadFull(dr.r, lb); err != nil {
+			return 0, ErrCiphertext
+		}
+		size := binary.BigEndian.Uint32(lb)
+		if size > cryptChunk+uint32(dr.aead.Overhead()) {
+			return 0, ErrCiphertext
+		}
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(dr.r, sealed); err != nil {
+			return 0, ErrCiphertext
+		}
+		// try as intermediate chunk, then as last chunk
+		plain, err := dr.aead.Open(nil, chunkNonce(dr.prefix, dr.ctr, false), sealed, nil)
+		if err != nil {
+			plain, err = dr.aead.Open(nil, chunkNonce(dr.prefix, dr.ctr, true), sealed, nil)
+			if err != nil {
+				return 0, ErrCiphertext
+			}
+			dr.done = true
+		}
+		dr.ctr++
+		dr.buf = plain
+	}
+	n := copy(p, dr.buf)
+	dr.buf = dr.buf[n:]
+	return n, nil
+}
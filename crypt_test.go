@@ -0,0 +1,118 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+var testKey = StaticKey{ID: "k1", Val: bytes.Repeat([]byte{7}, 32)}
+
+func encrypt(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	ew, err := NewEncryptWriter(buf, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ew.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(ciph []byte) ([]byte, error) {
+	dr, err := NewDecryptReader(bytes.NewReader(ciph), testKey)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+// segments splits a ciphertext into its header and its length prefixed chunks
+func segments(t *testing.T, ciph []byte) ([]byte, [][]byte) {
+	t.Helper()
+	hl := len(cryptMagic) + 1 + len(testKey.ID) + 7
+	hdr, rest := ciph[:hl], ciph[hl:]
+	var segs [][]byte
+	for len(rest) > 0 {
+		size := 4 + int(binary.BigEndian.Uint32(rest[:4]))
+		segs = append(segs, rest[:size])
+		rest = rest[size:]
+	}
+	return hdr, segs
+}
+
+func TestCryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, cryptChunk - 1, cryptChunk, cryptChunk + 1, 3*cryptChunk + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		got, err := decrypt(encrypt(t, plain))
+		if err != nil {
+			t.Errorf("size %v: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %v: plaintext differs", size)
+		}
+	}
+}
+
+func TestCryptTruncated(t *testing.T) {
+	plain := make([]byte, 3*cryptChunk)
+	ciph := encrypt(t, plain)
+	hdr, segs := segments(t, ciph)
+	if len(segs) != 3 {
+		t.Fatalf("%v chunks, want 3", len(segs))
+	}
+
+	cases := map[string][]byte{
+		"last chunk cut off": append(append([]byte{}, hdr...), bytes.Join(segs[:2], nil)...),
+		"cut within a chunk": ciph[:len(ciph)-10],
+		"header only":        hdr,
+		"partial header":     hdr[:4],
+	}
+	for name, c := range cases {
+		if _, err := decrypt(c); !errors.Is(err, ErrCiphertext) {
+			t.Errorf("%v: got %v, want ErrCiphertext", name, err)
+		}
+	}
+}
+
+func TestCryptReordered(t *testing.T) {
+	plain := make([]byte, 3*cryptChunk)
+	rand.Read(plain)
+	hdr, segs := segments(t, encrypt(t, plain))
+
+	swapped := append([]byte{}, hdr...)
+	for _, i := range []int{1, 0, 2} {
+		swapped = append(swapped, segs[i]...)
+	}
+	if _, err := decrypt(swapped); !errors.Is(err, ErrCiphertext) {
+		t.Errorf("swapped chunks: got %v, want ErrCiphertext", err)
+	}
+
+	// the last chunk must not pass for an intermediate one
+	moved := append([]byte{}, hdr...)
+	moved = append(moved, segs[2]...)
+	if dr, err := NewDecryptReader(bytes.NewReader(moved), testKey); err != nil {
+		t.Fatal(err)
+	} else if _, err := io.Copy(ioutil.Discard, dr); !errors.Is(err, ErrCiphertext) {
+		t.Errorf("last chunk first: got %v, want ErrCiphertext", err)
+	}
+}
+
+func TestCryptUnknownKey(t *testing.T) {
+	ciph := encrypt(t, []byte("secret"))
+	other := StaticKey{ID: "k2", Val: testKey.Val}
+	if _, err := NewDecryptReader(bytes.NewReader(ciph), other); err == nil {
+		t.Error("decrypted with an unknown key id")
+	}
+}
@@ -0,0 +1,151 @@
+package fetch
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+var ErrDecompressionBomb = errors.New("decompression bomb")
+
+// requestCompression asks for compression explicitly.
+// The stdlib would ask for gzip itself, but then decompress
+// out of our sight - leaving us no way to compare
+// compressed and decoded size.
+// Range requests are left alone: byte ranges of an encoded
+// representation can not be decoded - nor resumed - on their own.
+func (f *Job) requestCompression() {
+	if f.Req.Header.Get("Accept-Encoding") == "" && f.Req.Method != "HEAD" && f.Req.Header.Get("Range") == "" {
+		f.Req.Header.Set("Accept-Encoding", "gzip, br, zstd")
+	}
+}
+
+// zstdWindow is the maximum window of RFC 9659
+const zstdWindow = 8 << 20
+
+// decodedBody returns the body reader,
+// decompressing gzip, brotli or zstd, if the server sent it.
+// BytesOnWire and BytesDecoded are counted while reading.
+func (f *Job) decodedBody(resp *http.Response) (io.Reader, error) {
+	f.decoding = ""
+	f.BytesOnWire, f.BytesDecoded, f.Encoding = 0, 0, ""
+	raw := &countingReader{r: resp.Body, total: &f.BytesOnWire}
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if resp.Uncompressed {
+		enc = ""
+	}
+	f.Encoding = enc
+
+	var r io.Reader
+	switch enc {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(raw)
+		if err != nil {
+			return nil, fmt.Errorf("gzip body: %v", err)
+		}
+		r = gz
+	case "br":
+		r = brotli.NewReader(raw)
+	case "zstd":
+		zd, err := zstd.NewReader(raw, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdWindow))
+		if err != nil {
+			return nil, fmt.Errorf("zstd body: %v", err)
+		}
+		r = &closeOnEOF{r: zd, close: zd.Close}
+	default:
+		return &countingReader{r: raw, total: &f.BytesDecoded}, nil
+	}
+	f.decoding = enc
+	r = &bombGuard{r: r, compressed: raw, ratio: f.decompressRatio(), max: f.MaxDecompressed}
+	return &countingReader{r: r, total: &f.BytesDecoded}, nil
+}
+
+// closeOnEOF releases the zstd decoder
+type closeOnEOF struct {
+	r     io.Reader
+	close func()
+}
+
+func (c *closeOnEOF) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && c.close != nil {
+		c.close()
+		c.close = nil
+	}
+	return n, err
+}
+
+func (f *Job) decompressRatio() float64 {
+	if f.MaxDecompressRatio == 0 {
+		return 200
+	}
+	return f.MaxDecompressRatio
+}
+
+type countingReader struct {
+	r     io.Reader
+	n     int64
+	total *int64 // optional, also incremented
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.total != nil {
+		*c.total += int64(n)
+	}
+	return n, err
+}
+
+// bombGuard fails, once the decoded size exceeds max
+// or ratio times the compressed bytes read so far.
+// The first megabyte is never subject to the ratio.
+type bombGuard struct {
+	r          io.Reader
+	compressed *countingReader
+	ratio      float64
+	max        int64
+	decoded    int64
+}
+
+const bombSlack = 1 << 20
+
+func (b *bombGuard) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.decoded += int64(n)
+	if b.max > 0 && b.decoded > b.max {
+		return n, fmt.Errorf("%w: more than %v bytes decoded", ErrDecompressionBomb, b.max)
+	}
+	if b.ratio > 0 && b.decoded > bombSlack && float64(b.decoded) > b.ratio*float64(b.compressed.n) {
+		return n, fmt.Errorf("%w: %v bytes from %v compressed", ErrDecompressionBomb, b.decoded, b.compressed.n)
+	}
+	return n, err
+}
+
+// CompressionRatio of decoded to received body bytes;
+// 1 for uncompressed bodies.
+func (j *Job) CompressionRatio() float64 {
+	if j.BytesOnWire == 0 || j.BytesDecoded == 0 {
+		return 1
+	}
+	return float64(j.BytesDecoded) / float64(j.BytesOnWire)
+}
+
+// checkCompression notes servers ignoring our Accept-Encoding
+// for bodies worth compressing.
+func (f *Job) checkCompression() {
+	if f.Encoding != "" || f.Req.Header.Get("Accept-Encoding") == "" || f.BytesDecoded < 1024 {
+		return
+	}
+	ct := f.ContentType()
+	if isText(ct) || strings.Contains(ct, "json") || strings.Contains(ct, "javascript") {
+		f.event("compression", "%v bytes of %v sent uncompressed despite Accept-Encoding %q",
+			f.BytesDecoded, ct, f.Req.Header.Get("Accept-Encoding"))
+	}
+}
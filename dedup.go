@@ -0,0 +1,222 @@
+package fetch
+
+import (
+	"hash/fnv"
+	"html"
+	"math/bits"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Dedup detects duplicate pages in a crawl.
+// Content is normalized before hashing - markup, scripts, styles,
+// entities, case and whitespace do not count.
+// With Simhash, near duplicates - i.e. differing in a date or a counter - are found too.
+// Pages declaring the same <link rel="canonical"> are duplicates regardless of content.
+// Share one instance across jobs; Batch.Dedup does so.
+type Dedup struct {
+	Simhash     bool
+	MaxDistance int // of simhashes in bits; default 3, at most 3
+
+	mu        sync.Mutex
+	exact     map[uint64]int // content hash => cluster
+	canonical map[string]int // canonical url => cluster
+	bands     map[uint64][]int
+	hashes    []uint64   // simhash per cluster
+	clusters  [][]string // urls per cluster
+}
+
+// NewDedup for exact or near duplicates.
+func NewDedup(simhash bool) *Dedup {
+	return &Dedup{Simhash: simhash}
+}
+
+var (
+	dedupScript    = regexp.MustCompile(`(?is)<(script|style|noscript)\b.*?</(script|style|noscript)>`)
+	dedupTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	dedupSpace     = regexp.MustCompile(`\s+`)
+	dedupCanonical = regexp.MustCompile(`(?i)<link[^>]+rel=["']?canonical["']?[^>]*>`)
+	dedupHref      = regexp.MustCompile(`(?i)href=["']?([^"'\s>]+)`)
+)
+
+// normalizeContent reduces html to its lower case words
+func normalizeContent(bts []byte) string {
+	s := dedupScript.ReplaceAllString(string(bts), " ")
+	s = dedupTag.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	s = strings.ToLower(s)
+	return strings.TrimSpace(dedupSpace.ReplaceAllString(s, " "))
+}
+
+func canonicalLink(bts []byte) string {
+	link := dedupCanonical.Find(bts)
+	if link == nil {
+		return ""
+	}
+	m := dedupHref.FindSubmatch(link)
+	if m == nil {
+		return ""
+	}
+	key, _, ok := frontierKey(html.UnescapeString(string(m[1])))
+	if !ok {
+		return ""
+	}
+	return key
+}
+
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// simhash over word shingles of three
+func simhash(text string) uint64 {
+	words := strings.Fields(text)
+	var v [64]int
+	for i := 0; i+3 <= len(words) || (i == 0 && len(words) > 0); i++ {
+		end := i + 3
+		if end > len(words) {
+			end = len(words)
+		}
+		h := hash64(strings.Join(words[i:end], " "))
+		for b := 0; b < 64; b++ {
+			if h&(1<<uint(b)) != 0 {
+				v[b]++
+			} else {
+				v[b]--
+			}
+		}
+	}
+	var sh uint64
+	for b := 0; b < 64; b++ {
+		if v[b] > 0 {
+			sh |= 1 << uint(b)
+		}
+	}
+	return sh
+}
+
+// simhash bands of 16 bits; with four bands, hashes within
+// three bits of each other share at least one band
+func simBands(sh uint64) [4]uint64 {
+	var b [4]uint64
+	for i := range b {
+		b[i] = uint64(i)<<16 | (sh>>(16*uint(i)))&0xffff
+	}
+	return b
+}
+
+// Check records the page at url; if it duplicates an earlier one,
+// the url of the first page of that cluster is returned.
+// Pages without text are only matched by their canonical link;
+// refetching the first url of a cluster is no duplicate.
+func (d *Dedup) Check(url string, body []byte) (string, bool) {
+	canon := canonicalLink(body)
+	text := normalizeContent(body)
+	if text == "" && canon == "" {
+		return "", false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.exact == nil {
+		d.exact, d.canonical, d.bands = map[uint64]int{}, map[string]int{}, map[uint64][]int{}
+	}
+
+	cluster := -1
+	if c, ok := d.canonical[canon]; ok && canon != "" {
+		cluster = c
+	}
+	h := hash64(text)
+	if c, ok := d.exact[h]; ok && cluster < 0 && text != "" {
+		cluster = c
+	}
+	var sh uint64
+	if d.Simhash && text != "" {
+		sh = simhash(text)
+		maxDist := d.MaxDistance
+		if maxDist <= 0 || maxDist > 3 {
+			maxDist = 3
+		}
+		for _, band := range simBands(sh) {
+			if cluster >= 0 {
+				break
+			}
+			for _, c := range d.bands[band] {
+				if bits.OnesCount64(d.hashes[c]^sh) <= maxDist {
+					cluster = c
+					break
+				}
+			}
+		}
+	}
+
+	if cluster >= 0 {
+		members := d.clusters[cluster]
+		if members[0] == url {
+			return "", false
+		}
+		if !containsString(members, url) {
+			d.clusters[cluster] = append(members, url)
+		}
+		if canon != "" {
+			d.canonical[canon] = cluster
+		}
+		return members[0], true
+	}
+
+	cluster = len(d.clusters)
+	d.clusters = append(d.clusters, []string{url})
+	d.hashes = append(d.hashes, sh)
+	if text != "" {
+		d.exact[h] = cluster
+	}
+	if canon != "" {
+		d.canonical[canon] = cluster
+	}
+	if d.Simhash && text != "" {
+		for _, band := range simBands(sh) {
+			d.bands[band] = append(d.bands[band], cluster)
+		}
+	}
+	return "", false
+}
+
+// Clusters returns the groups of duplicate urls, each in order of discovery.
+func (d *Dedup) Clusters() [][]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ret := [][]string{}
+	for _, c := range d.clusters {
+		if len(c) > 1 {
+			ret = append(ret, append([]string(nil), c...))
+		}
+	}
+	return ret
+}
+
+// dedup - called from finish
+func (f *Job) dedup() {
+	f.DuplicateOf = ""
+	if f.Dedup == nil || f.Err != nil || f.Status < 200 || f.Status > 299 || len(f.bts) == 0 {
+		return
+	}
+	u := f.URL
+	if f.Req != nil {
+		u = f.Req.URL.String()
+	}
+	if first, ok := f.Dedup.Check(u, f.bts); ok {
+		f.DuplicateOf = first
+		f.event("duplicate", "content duplicates %v", first)
+	}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package fetch
+
+import (
+	"google.golang.org/appengine"
+)
+
+// Toggle is a tri-state setting
+type Toggle int
+
+const (
+	Auto Toggle = iota // the historic behavior
+	On
+	Off
+)
+
+// devDowngrade makes the dev server behavior explicit;
+// staging can reproduce production TLS behavior with Off.
+func (f *Job) devDowngrade() bool {
+	switch f.DevDowngrade {
+	case On:
+		return true
+	case Off:
+		return false
+	}
+	return f.clientKind == "urlfetch" && appengine.IsDevAppServer() && !f.ForceHttps
+}
+
+func (f *Job) certFallback() bool {
+	return f.CertFallback != Off
+}
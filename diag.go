@@ -0,0 +1,80 @@
+package fetch
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Diagnostics is a snapshot of the fetch environment,
+// making "works on my machine" failures between
+// appengine and standalone debuggable.
+type Diagnostics struct {
+	Environment string   // appengine, appengine-dev, cloudrun, functions, standalone
+	Client      string   // urlfetch or standard
+	Transport   string   // go type of the transport
+	Proxy       string   // proxy in effect for the request, if any
+	Resolver    string   // go or cgo
+	Nameservers []string // from /etc/resolv.conf
+	LocalAddr   string   // egress address of the last connection, if any
+	Hostname    string
+	GoVersion   string
+	Platform    string
+}
+
+func (d Diagnostics) String() string {
+	return fmt.Sprintf("env %v, client %v, transport %v, proxy %q, resolver %v %v, local %v, host %v, %v %v",
+		d.Environment, d.Client, d.Transport, d.Proxy, d.Resolver, d.Nameservers,
+		d.LocalAddr, d.Hostname, d.GoVersion, d.Platform)
+}
+
+func (f *Job) diagnostics() *Diagnostics {
+	d := &Diagnostics{
+		Environment: string(f.env()),
+		Client:      f.clientKind,
+		LocalAddr:   f.localAddr,
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		Resolver:    "cgo",
+	}
+	d.Hostname, _ = os.Hostname()
+	if net.DefaultResolver.PreferGo {
+		d.Resolver = "go"
+	}
+	d.Nameservers = nameservers()
+
+	if f.client != nil {
+		var tr http.RoundTripper = f.client.Transport
+		if tr == nil {
+			tr = http.DefaultTransport
+		}
+		d.Transport = fmt.Sprintf("%T", tr)
+		if ht, ok := tr.(*http.Transport); ok && ht.Proxy != nil && f.Req != nil {
+			if pu, err := ht.Proxy(f.Req); err == nil && pu != nil {
+				d.Proxy = pu.Redacted()
+			}
+		}
+	}
+	return d
+}
+
+func nameservers() []string {
+	fl, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer fl.Close()
+	ret := []string{}
+	sc := bufio.NewScanner(fl)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 1 && fields[0] == "nameserver" {
+			ret = append(ret, fields[1])
+		}
+	}
+	return ret
+}
@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// DiffRunner fetches the same urls through two configurations -
+// i.e. old and new proxy, or staging and production headers -
+// and reports the differences. Invaluable during migrations.
+type DiffRunner struct {
+	A, B    []Option // the two configurations
+	Workers int      // per side; default 8
+
+	// Normalize optionally strips volatile content -
+	// timestamps, nonces, csrf tokens - before bodies are compared.
+	Normalize func(body []byte) []byte
+}
+
+// Difference of one url; Equal if status, error and body agree.
+type Difference struct {
+	URL              string
+	Equal            bool
+	StatusA, StatusB int
+	ErrA, ErrB       string `json:",omitempty"`
+	SizeA, SizeB     int
+	HashA, HashB     string // sha256 of the normalized body
+	FirstDiff        int    // byte offset of the first differing byte; -1 if equal
+}
+
+func (d Difference) String() string {
+	if d.Equal {
+		return fmt.Sprintf("equal     %v", d.URL)
+	}
+	s := fmt.Sprintf("differs   %v: status %v vs %v, size %v vs %v", d.URL, d.StatusA, d.StatusB, d.SizeA, d.SizeB)
+	if d.ErrA != d.ErrB {
+		s += fmt.Sprintf(", err %q vs %q", d.ErrA, d.ErrB)
+	}
+	if d.FirstDiff >= 0 {
+		s += fmt.Sprintf(", bodies differ from byte %v", d.FirstDiff)
+	}
+	return s
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Run fetches both sides concurrently.
+// The differences are returned in the order of urls.
+func (r *DiffRunner) Run(ctx context.Context, urls []string) []Difference {
+	sides := []*Batch{NewBatch(urls, r.A...), NewBatch(urls, r.B...)}
+	wg := sync.WaitGroup{}
+	for _, b := range sides {
+		b.Workers = r.Workers
+		wg.Add(1)
+		go func(b *Batch) {
+			defer wg.Done()
+			b.Run(ctx)
+		}(b)
+	}
+	wg.Wait()
+
+	ret := make([]Difference, len(urls))
+	for i := range urls {
+		ja, jb := sides[0].Jobs[i], sides[1].Jobs[i]
+		ba, bb := ja.bts, jb.bts
+		if r.Normalize != nil {
+			ba, bb = r.Normalize(ba), r.Normalize(bb)
+		}
+		ha, hb := sha256.Sum256(ba), sha256.Sum256(bb)
+		d := Difference{
+			URL:       urls[i],
+			StatusA:   ja.Status,
+			StatusB:   jb.Status,
+			ErrA:      errString(ja.Err),
+			ErrB:      errString(jb.Err),
+			SizeA:     len(ba),
+			SizeB:     len(bb),
+			HashA:     hex.EncodeToString(ha[:]),
+			HashB:     hex.EncodeToString(hb[:]),
+			FirstDiff: firstDiff(ba, bb),
+		}
+		d.Equal = d.StatusA == d.StatusB && d.ErrA == d.ErrB && d.FirstDiff < 0
+		ret[i] = d
+	}
+	return ret
+}
+
+func firstDiff(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) < len(b) {
+		return len(a)
+	}
+	return len(b)
+}
+
+// DiffReport renders the differing urls only, plus a count of the equal ones.
+func DiffReport(diffs []Difference) string {
+	b := &strings.Builder{}
+	equal := 0
+	for _, d := range diffs {
+		if d.Equal {
+			equal++
+			continue
+		}
+		fmt.Fprintln(b, d)
+	}
+	fmt.Fprintf(b, "%v of %v urls equal\n", equal, len(diffs))
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package fetch
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// DigestAuth answers a 401 digest challenge (RFC 7616)
+// by retrying once with the computed response.
+// Supports MD5, SHA-256 and their -sess variants, with qop auth
+// or without qop (RFC 2069). The password never goes to Msg.
+func (j *Job) DigestAuth(user, pass string) {
+	j.digestUser, j.digestPass = user, pass
+}
+
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm, qop string
+	userhash                             bool
+}
+
+// parseDigest reads the params of a "Digest ..." challenge.
+// Quoted values may contain commas.
+func parseDigest(hdr string) (digestChallenge, bool) {
+	c := digestChallenge{}
+	if len(hdr) < 7 || !strings.EqualFold(hdr[:7], "digest ") {
+		return c, false
+	}
+	params := map[string]string{}
+	s := hdr[7:]
+	for {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+		val := ""
+		if strings.HasPrefix(s, `"`) {
+			b := strings.Builder{}
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			val = b.String()
+			if i < len(s) {
+				i++ // closing quote
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = val
+	}
+
+	c.realm, c.nonce, c.opaque = params["realm"], params["nonce"], params["opaque"]
+	c.algorithm = strings.ToUpper(params["algorithm"])
+	if c.algorithm == "" {
+		c.algorithm = "MD5"
+	}
+	c.userhash = strings.EqualFold(params["userhash"], "true")
+	if qops, ok := params["qop"]; ok {
+		for _, q := range strings.Split(qops, ",") {
+			if strings.TrimSpace(q) == "auth" {
+				c.qop = "auth"
+			}
+		}
+		if c.qop == "" {
+			return c, false // auth-int only
+		}
+	}
+	if c.newHash() == nil || c.nonce == "" {
+		return c, false
+	}
+	return c, true
+}
+
+func (c digestChallenge) newHash() hash.Hash {
+	switch strings.TrimSuffix(c.algorithm, "-SESS") {
+	case "MD5":
+		return md5.New()
+	case "SHA-256":
+		return sha256.New()
+	}
+	return nil
+}
+
+func (c digestChallenge) h(s string) string {
+	hs := c.newHash()
+	io.WriteString(hs, s)
+	return hex.EncodeToString(hs.Sum(nil))
+}
+
+// authorization computes the Authorization header value
+func (c digestChallenge) authorization(user, pass, method, uri string) string {
+	cnonceB := make([]byte, 16)
+	rand.Read(cnonceB)
+	return c.authorizationCnonce(user, pass, method, uri, hex.EncodeToString(cnonceB))
+}
+
+func (c digestChallenge) authorizationCnonce(user, pass, method, uri, cnonce string) string {
+	nc := "00000001"
+
+	ha1 := c.h(user + ":" + c.realm + ":" + pass)
+	if strings.HasSuffix(c.algorithm, "-SESS") {
+		ha1 = c.h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := c.h(method + ":" + uri)
+	response := c.h(ha1 + ":" + c.nonce + ":" + ha2)
+	if c.qop != "" {
+		response = c.h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	if c.userhash {
+		user = c.h(user + ":" + c.realm)
+	}
+	parts := []string{
+		fmt.Sprintf("username=%q", user),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("algorithm=%v", c.algorithm),
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.qop != "" {
+		parts = append(parts, "qop="+c.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	if c.opaque != "" {
+		parts = append(parts, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.userhash {
+		parts = append(parts, "userhash=true")
+	}
+	return "Digest " + strings.Join(parts, ", ")
+}
+
+// digestRetry answers a digest challenge once.
+// The strongest offered algorithm wins.
+func (f *Job) digestRetry(client *http.Client, resp *http.Response) (*http.Response, error) {
+	if f.digestUser == "" || resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	var chosen *digestChallenge
+	for _, hdr := range resp.Header.Values("WWW-Authenticate") {
+		c, ok := parseDigest(hdr)
+		if !ok {
+			continue
+		}
+		if chosen == nil || strings.HasPrefix(c.algorithm, "SHA-256") && !strings.HasPrefix(chosen.algorithm, "SHA-256") {
+			chosen = &c
+		}
+	}
+	if chosen == nil || !f.rewindBody() {
+		return resp, nil
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	f.setAttemptHeader("Authorization", chosen.authorization(f.digestUser, f.digestPass, f.Req.Method, f.Req.URL.RequestURI()))
+	f.event("auth", "digest challenge %v, realm %q; retrying", chosen.algorithm, chosen.realm)
+	f.log(slog.LevelInfo, "authorization [REDACTED]", "scheme", "Digest")
+	return client.Do(f.Req)
+}
@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The examples of RFC 7616, section 3.9.1
+const (
+	rfcUser   = "Mufasa"
+	rfcPass   = "Circle of Life"
+	rfcCnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	rfcParams = `realm="http-auth@example.org", qop="auth, auth-int", ` +
+		`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", ` +
+		`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+)
+
+func TestDigestRFC7616(t *testing.T) {
+	cases := []struct {
+		challenge, response string
+	}{
+		{`Digest ` + rfcParams + `, algorithm=MD5`, "8ca523f5e9506fed4657c9700eebdbec"},
+		{`Digest ` + rfcParams + `, algorithm=SHA-256`, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tc := range cases {
+		c, ok := parseDigest(tc.challenge)
+		if !ok {
+			t.Fatalf("cannot parse %v", tc.challenge)
+		}
+		if c.realm != "http-auth@example.org" || c.qop != "auth" || c.opaque != "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS" {
+			t.Errorf("parsed %+v", c)
+		}
+		got := c.authorizationCnonce(rfcUser, rfcPass, "GET", "/dir/index.html", rfcCnonce)
+		for _, want := range []string{
+			`username="Mufasa"`,
+			`uri="/dir/index.html"`,
+			`algorithm=` + c.algorithm,
+			`response="` + tc.response + `"`,
+			`qop=auth`,
+			`nc=00000001`,
+			`cnonce="` + rfcCnonce + `"`,
+			`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%v: %v lacks %v", c.algorithm, got, want)
+			}
+		}
+	}
+}
+
+func TestDigestParse(t *testing.T) {
+	if _, ok := parseDigest(`Basic realm="x"`); ok {
+		t.Error("basic challenge taken for digest")
+	}
+	if _, ok := parseDigest(`Digest realm="x", nonce="n", qop="auth-int"`); ok {
+		t.Error("auth-int only challenge accepted")
+	}
+	if _, ok := parseDigest(`Digest realm="x", nonce="n", algorithm=SHA-512-256`); ok {
+		t.Error("unsupported algorithm accepted")
+	}
+	c, ok := parseDigest(`Digest realm="a, \"b\"", nonce="n"`)
+	if !ok || c.realm != `a, "b"` || c.algorithm != "MD5" || c.qop != "" {
+		t.Errorf("parsed %+v, %v", c, ok)
+	}
+}
+
+// SHA-256 is preferred, and the response is not sent again
+// with the next fetch of the job.
+func TestDigestRetry(t *testing.T) {
+	var auths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		auths = append(auths, auth)
+		if auth == "" {
+			w.Header().Add("WWW-Authenticate", `Digest realm="r", nonce="n", qop="auth", algorithm=MD5`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="r", nonce="n", qop="auth", algorithm=SHA-256`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	j := &Job{URL: srv.URL}
+	j.DigestAuth("u", "p")
+	j.Fetch()
+	j.Fetch()
+	if j.Err != nil || j.Status != http.StatusOK {
+		t.Fatalf("status %v, err %v", j.Status, j.Err)
+	}
+	if len(auths) != 4 || auths[0] != "" || auths[2] != "" {
+		t.Fatalf("authorizations %q; want none on the first request of each fetch", auths)
+	}
+	if !strings.Contains(auths[1], "algorithm=SHA-256") {
+		t.Errorf("got %v, want SHA-256", auths[1])
+	}
+}
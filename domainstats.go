@@ -0,0 +1,226 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// DomainStat aggregates the fetches against one registrable domain,
+// i.e. example.co.uk for www.example.co.uk.
+type DomainStat struct {
+	Domain   string
+	Requests int
+	Failures int               // Kind other than KindNone
+	Kinds    map[ErrorKind]int // failures by class
+	Statuses map[int]int
+	Bytes    int64 // decoded
+	Wire     int64 // received, compressed
+
+	// latency quantiles over the most recent requests
+	P50, P90, P99 time.Duration
+}
+
+type domainStat struct {
+	stat      DomainStat
+	latencies []time.Duration // ring buffer
+	next      int
+}
+
+// DomainStats collects statistics per domain for offline analysis of crawls;
+// share one instance across all jobs.
+// The zero value keeps the latencies of the last 1000 requests.
+type DomainStats struct {
+	mu      sync.Mutex
+	window  int
+	domains map[string]*domainStat
+}
+
+// NewDomainStats keeps the latencies of the last window requests per domain.
+func NewDomainStats(window int) *DomainStats {
+	if window < 1 {
+		window = 1000
+	}
+	return &DomainStats{window: window}
+}
+
+func jobDomain(j *Job) string {
+	host := jobHost(j)
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if dom, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return dom
+	}
+	return host
+}
+
+// Record adds a finished job;
+// results answered by the Cache alone are not counted.
+func (s *DomainStats) Record(j *Job) {
+	if j.local() {
+		return
+	}
+	dom := jobDomain(j)
+	if dom == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.domains == nil {
+		s.domains = map[string]*domainStat{}
+	}
+	if s.window < 1 {
+		s.window = 1000
+	}
+	ds, ok := s.domains[dom]
+	if !ok {
+		ds = &domainStat{stat: DomainStat{Domain: dom, Kinds: map[ErrorKind]int{}, Statuses: map[int]int{}}}
+		s.domains[dom] = ds
+	}
+	ds.stat.Requests++
+	ds.stat.Statuses[j.Status]++
+	ds.stat.Bytes += int64(len(j.bts))
+	ds.stat.Wire += j.BytesOnWire
+	if j.Kind != KindNone {
+		ds.stat.Failures++
+		ds.stat.Kinds[j.Kind]++
+	}
+	if len(ds.latencies) < s.window {
+		ds.latencies = append(ds.latencies, j.Elapsed)
+	} else {
+		ds.latencies[ds.next] = j.Elapsed
+		ds.next = (ds.next + 1) % s.window
+	}
+}
+
+func (ds *domainStat) snapshot() DomainStat {
+	st := ds.stat
+	st.Kinds, st.Statuses = map[ErrorKind]int{}, map[int]int{}
+	for k, v := range ds.stat.Kinds {
+		st.Kinds[k] = v
+	}
+	for k, v := range ds.stat.Statuses {
+		st.Statuses[k] = v
+	}
+	lats := append([]time.Duration(nil), ds.latencies...)
+	sort.Slice(lats, func(i, k int) bool { return lats[i] < lats[k] })
+	if n := len(lats); n > 0 {
+		st.P50, st.P90, st.P99 = lats[n*50/100], lats[n*90/100], lats[n*99/100]
+	}
+	return st
+}
+
+// All returns the statistics of all domains, sorted by domain.
+func (s *DomainStats) All() []DomainStat {
+	s.mu.Lock()
+	ret := make([]DomainStat, 0, len(s.domains))
+	for _, ds := range s.domains {
+		ret = append(ret, ds.snapshot())
+	}
+	s.mu.Unlock()
+	sort.Slice(ret, func(i, k int) bool { return ret[i].Domain < ret[k].Domain })
+	return ret
+}
+
+var domainKinds = []ErrorKind{KindDNS, KindConnect, KindTLS, KindTimeout, KindRedirect, KindBodyRead, KindHTTPStatus, KindCircuit, KindOther}
+
+// WriteCSV writes one row per domain; latencies in milliseconds.
+// Status counts are aggregated to classes 2xx to 5xx.
+func (s *DomainStats) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	hdr := []string{"domain", "requests", "failures", "bytes", "wire", "p50_ms", "p90_ms", "p99_ms", "2xx", "3xx", "4xx", "5xx"}
+	for _, k := range domainKinds {
+		hdr = append(hdr, string(k))
+	}
+	if err := cw.Write(hdr); err != nil {
+		return err
+	}
+	ms := func(d time.Duration) string { return strconv.FormatInt(d.Milliseconds(), 10) }
+	for _, st := range s.All() {
+		classes := make([]int, 6)
+		for code, cnt := range st.Statuses {
+			if code/100 >= 2 && code/100 <= 5 {
+				classes[code/100] += cnt
+			}
+		}
+		row := []string{
+			st.Domain, strconv.Itoa(st.Requests), strconv.Itoa(st.Failures),
+			strconv.FormatInt(st.Bytes, 10), strconv.FormatInt(st.Wire, 10),
+			ms(st.P50), ms(st.P90), ms(st.P99),
+			strconv.Itoa(classes[2]), strconv.Itoa(classes[3]), strconv.Itoa(classes[4]), strconv.Itoa(classes[5]),
+		}
+		for _, k := range domainKinds {
+			row = append(row, strconv.Itoa(st.Kinds[k]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes All() as an indented array.
+func (s *DomainStats) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s.All())
+}
+
+// Export writes domains.csv and domains.json into dir every interval,
+// replacing the previous files atomically,
+// until the returned stop func is called - which writes a last time.
+// interval defaults to one minute.
+func (s *DomainStats) Export(dir string, interval time.Duration, onErr func(error)) (stop func()) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	store := FileStore{Dir: dir}
+	write := func() {
+		csvBuf, jsonBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		err := s.WriteCSV(csvBuf)
+		if err == nil {
+			err = store.Save("domains.csv", csvBuf.Bytes())
+		}
+		if err == nil {
+			err = s.WriteJSON(jsonBuf)
+		}
+		if err == nil {
+			err = store.Save("domains.json", jsonBuf.Bytes())
+		}
+		if err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C:
+				write()
+			case <-done:
+				ticker.Stop()
+				write()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
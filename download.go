@@ -0,0 +1,161 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WithResume lets DownloadFile continue partial downloads.
+func WithResume() Option {
+	return func(j *Job) {
+		j.Resume = true
+	}
+}
+
+// DownloadFile saves url to path.
+// The body goes into a temp file, which is fsynced
+// and renamed onto path on success only - readers never see partial files.
+// With WithResume(), the temp file is path + ".part", and
+// an existing one is continued with a range request.
+// The ETag or Last-Modified of the first response is kept
+// in path + ".part.validator" and sent as If-Range;
+// if the file has changed, the server sends it whole and we start over.
+func DownloadFile(url, path string, opts ...Option) (*Job, error) {
+
+	j := New(url, opts...)
+	j.Stream = true
+
+	var fl *os.File
+	var err error
+	offset := int64(0)
+	validatorFile := path + ".part.validator"
+	if j.Resume {
+		fl, err = os.OpenFile(path+".part", os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return j, err
+		}
+		offset, err = fl.Seek(0, io.SeekEnd)
+		if err != nil {
+			fl.Close()
+			return j, err
+		}
+		if offset > 0 {
+			if v, err := ioutil.ReadFile(validatorFile); err == nil && len(v) > 0 {
+				if j.Headers == nil {
+					j.Headers = http.Header{}
+				}
+				j.Headers.Set("If-Range", string(v))
+			} else {
+				offset = 0 // no way to tell whether the partial file is still current
+			}
+		}
+	} else {
+		fl, err = ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+		if err != nil {
+			return j, err
+		}
+	}
+	tmp := fl.Name()
+	fail := func(err error) (*Job, error) {
+		fl.Close()
+		if !j.Resume {
+			os.Remove(tmp)
+		}
+		j.Err = err
+		return j, err
+	}
+
+	if j.Resume {
+		if j.Headers == nil {
+			j.Headers = http.Header{}
+		}
+		j.Headers.Set("Accept-Encoding", "identity") // ranges refer to the raw file
+		if offset > 0 {
+			j.Headers.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	}
+
+	j.Fetch()
+	if j.Err != nil {
+		return fail(j.Err)
+	}
+	body := j.Body()
+	defer body.Close()
+
+	switch {
+	case j.Status == http.StatusPartialContent && offset > 0:
+		if start, ok := contentRangeStart(j.RespHeader.Get("Content-Range")); !ok || start != offset {
+			return fail(fmt.Errorf("range response starts at %v, expected %v", start, offset))
+		}
+		j.log(slog.LevelInfo, "resuming", "offset", offset)
+	case j.Status == http.StatusOK:
+		if offset > 0 {
+			j.log(slog.LevelWarn, "server ignored range request; restarting")
+		}
+		if err := fl.Truncate(0); err != nil {
+			return fail(err)
+		}
+		if _, err := fl.Seek(0, io.SeekStart); err != nil {
+			return fail(err)
+		}
+		if j.Resume {
+			os.Remove(validatorFile)
+			if v := validator(j.RespHeader); v != "" {
+				if err := ioutil.WriteFile(validatorFile, []byte(v), 0644); err != nil {
+					return fail(err)
+				}
+			}
+		}
+	case j.Status == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// presumably complete already - or changed; start over next time
+		fl.Close()
+		os.Remove(tmp)
+		os.Remove(validatorFile)
+		j.Err = fmt.Errorf("range not satisfiable at %v; partial file removed", offset)
+		return j, j.Err
+	default:
+		if err := j.StatusErr(); err != nil {
+			return fail(err)
+		}
+		return fail(fmt.Errorf("unexpected status %v for %v", j.Status, RedactURL(j.Req.URL.String())))
+	}
+
+	if _, err := io.Copy(fl, body); err != nil {
+		return fail(err)
+	}
+	if err := fl.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := fl.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fail(err)
+	}
+	if j.Resume {
+		os.Remove(validatorFile)
+	}
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync() // persist the rename
+		dir.Close()
+	}
+	return j, nil
+}
+
+// contentRangeStart parses "bytes 100-199/200"
+func contentRangeStart(cr string) (int64, bool) {
+	cr = strings.TrimPrefix(cr, "bytes ")
+	pos := strings.Index(cr, "-")
+	if pos < 0 {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(cr[:pos], 10, 64)
+	return start, err == nil
+}
@@ -0,0 +1,32 @@
+package fetch
+
+import (
+	"log/slog"
+	"net/http/httputil"
+)
+
+// dryRun dumps the fully prepared request into Wire,
+// instead of sending it.
+// Secret header values are redacted.
+func (f *Job) dryRun() error {
+	req := f.Req.Clone(f.Req.Context())
+	for hdr := range f.SecretHeaders {
+		if req.Header.Get(hdr) != "" {
+			req.Header.Set(hdr, "[REDACTED]")
+		}
+	}
+	if f.authz != "" {
+		req.Header.Set("Authorization", f.authzScheme+" [REDACTED]")
+	}
+	// DumpRequestOut restores the body for us
+	req.Body = f.Req.Body
+	dump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return err
+	}
+	f.Req.Body = req.Body
+	f.Wire = string(dump)
+	f.event("dryrun", "%v %v", f.Req.Method, f.Req.URL)
+	f.log(slog.LevelInfo, "dry run - nothing sent")
+	return nil
+}
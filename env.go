@@ -0,0 +1,98 @@
+package fetch
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine"
+)
+
+// Environment is where we run.
+// Job.Env selects the mode explicitly; empty means DetectEnvironment().
+type Environment string
+
+const (
+	EnvStandalone   = Environment("standalone")
+	EnvAppengine    = Environment("appengine")
+	EnvAppengineDev = Environment("appengine-dev")
+	EnvCloudRun     = Environment("cloudrun")
+	EnvFunctions    = Environment("functions")
+)
+
+// DetectEnvironment - we could use logx.IsAppengine()
+func DetectEnvironment() Environment {
+	switch {
+	case appengine.IsDevAppServer():
+		return EnvAppengineDev
+	case appengine.IsAppEngine():
+		return EnvAppengine
+	case os.Getenv("K_SERVICE") != "" && os.Getenv("FUNCTION_TARGET") != "":
+		return EnvFunctions
+	case os.Getenv("K_SERVICE") != "":
+		return EnvCloudRun
+	}
+	return EnvStandalone
+}
+
+// Serverless is true for Cloud Run and Cloud Functions.
+func (e Environment) Serverless() bool {
+	return e == EnvCloudRun || e == EnvFunctions
+}
+
+// Concurrency is the number of requests an instance serves at once.
+// Cloud Run does not tell us, so we take FETCH_CONCURRENCY
+// or the platform defaults: 80 for Cloud Run, 1 for Functions.
+func (e Environment) Concurrency() int {
+	if n, err := strconv.Atoi(os.Getenv("FETCH_CONCURRENCY")); err == nil && n > 0 {
+		return n
+	}
+	switch e {
+	case EnvCloudRun:
+		return 80
+	case EnvFunctions:
+		return 1
+	}
+	return 0
+}
+
+// ServerlessDialTimeout - instances are billed while waiting,
+// and a dead host should fail fast.
+var ServerlessDialTimeout = 3 * time.Second
+
+func (f *Job) env() Environment {
+	if f.Env != "" {
+		return f.Env
+	}
+	return DetectEnvironment()
+}
+
+var (
+	serverlessOnce sync.Once
+	serverlessTr   *http.Transport
+)
+
+// serverlessTransport is shared by all jobs of the instance.
+// Package level state survives between invocations of a warm instance,
+// thus connections are reused across invocations.
+// Connections per host are capped to the instance concurrency.
+func serverlessTransport(e Environment) *http.Transport {
+	serverlessOnce.Do(func() {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.DialContext = (&net.Dialer{
+			Timeout:   ServerlessDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		tr.TLSHandshakeTimeout = ServerlessDialTimeout
+		tr.IdleConnTimeout = 10 * time.Minute
+		if n := e.Concurrency(); n > 0 {
+			tr.MaxConnsPerHost = n
+			tr.MaxIdleConnsPerHost = n
+		}
+		serverlessTr = tr
+	})
+	return serverlessTr
+}
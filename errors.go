@@ -0,0 +1,134 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Classes of transport errors; test with errors.Is().
+// The original cause remains accessible via errors.As(),
+// i.e. *net.DNSError or x509.UnknownAuthorityError.
+var (
+	ErrRedirectBlocked = errors.New(MsgNoRedirects)
+	ErrTimeout         = errors.New("timeout")
+	ErrDNS             = errors.New("dns lookup failed")
+	ErrTLS             = errors.New("tls handshake or certificate failed")
+	ErrBodyRead        = errors.New("reading body failed")
+)
+
+// ErrSkipped - HeadFirst found the GET not worthwhile; Skipped tells why.
+// Status and RespHeader are those of the HEAD response.
+var ErrSkipped = errors.New("GET skipped after HEAD")
+
+// ErrBodyStalled - the response body sent no bytes for IdleTimeout.
+// Retried like a timeout, if the request is idempotent.
+var ErrBodyStalled = errors.New("response body stalled")
+
+// ErrBadStatus is returned by the helpers for non-2xx responses.
+// errors.Is(err, ErrBadStatus{}) matches any code,
+// errors.Is(err, ErrBadStatus{Code: 404}) only 404.
+type ErrBadStatus struct {
+	Code int
+	URL  string
+}
+
+func (e ErrBadStatus) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("status %v", e.Code)
+	}
+	return fmt.Sprintf("status %v for %v", e.Code, e.URL)
+}
+
+func (e ErrBadStatus) Is(target error) bool {
+	t, ok := target.(ErrBadStatus)
+	return ok && (t.Code == 0 || t.Code == e.Code)
+}
+
+// StatusErr is nil for 2xx responses, ErrBadStatus otherwise.
+func (j *Job) StatusErr() error {
+	if j.Status >= 200 && j.Status <= 299 {
+		return nil
+	}
+	u := j.URL
+	if j.Req != nil {
+		u = j.Req.URL.String()
+	}
+	return ErrBadStatus{Code: j.Status, URL: RedactURL(u)}
+}
+
+// classifiedError tags an error with one of the classes above
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string        { return fmt.Sprintf("%v: %v", e.class, e.err) }
+func (e *classifiedError) Unwrap() error        { return e.err }
+func (e *classifiedError) Is(target error) bool { return target == e.class }
+
+// bodyReadError tags failures after the response header arrived
+func bodyReadError(err error) error {
+	if err == nil || errors.Is(err, ErrBodyRead) {
+		return err
+	}
+	return &classifiedError{ErrBodyRead, err}
+}
+
+// plainHTTPServer - the server answered TLS with plain http,
+// or urlfetch refused the certificate.
+// Only these justify CertFallback; a failed verification never does -
+// it may well be an interceptor.
+func plainHTTPServer(err error) bool {
+	var recErr tls.RecordHeaderError
+	if errors.As(err, &recErr) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "server gave HTTP response to HTTPS client") || // net/http unwraps the RecordHeaderError
+		strings.Contains(msg, "tls: oversized record received") ||
+		strings.Contains(msg, "SSL_CERTIFICATE_ERROR")
+}
+
+// classify tags transport errors with ErrDNS, ErrTLS or ErrTimeout.
+// urlfetch on appengine only provides strings, which we match as a last resort.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *classifiedError
+	if errors.As(err, &ce) || errors.Is(err, ErrRedirectBlocked) {
+		return err
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &classifiedError{ErrDNS, err}
+	}
+
+	var (
+		recErr   tls.RecordHeaderError
+		alertErr tls.AlertError
+		verErr   *tls.CertificateVerificationError
+		authErr  x509.UnknownAuthorityError
+		hostErr  x509.HostnameError
+		certErr  x509.CertificateInvalidError
+	)
+	if errors.As(err, &recErr) || errors.As(err, &alertErr) || errors.As(err, &verErr) ||
+		errors.As(err, &authErr) || errors.As(err, &hostErr) || errors.As(err, &certErr) ||
+		strings.Contains(err.Error(), "SSL_CERTIFICATE_ERROR") {
+		return &classifiedError{ErrTLS, err}
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) ||
+		strings.Contains(err.Error(), "DEADLINE_EXCEEDED") {
+		return &classifiedError{ErrTimeout, err}
+	}
+	return err
+}
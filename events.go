@@ -0,0 +1,30 @@
+package fetch
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event is a noteworthy step of a fetch.
+// Unlike Msg, events are meant for machine inspection.
+type Event struct {
+	Time    time.Time
+	Kind    string
+	Msg     string
+	Attempt int    // counting from 1; 0 before the first attempt
+	TraceID string // of the fetch; see Job.TraceID
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%v %-10v %v", e.Time.Format("15:04:05.000"), e.Kind, e.Msg)
+}
+
+func (f *Job) event(kind, format string, args ...interface{}) {
+	f.Events = append(f.Events, Event{
+		Time:    time.Now(),
+		Kind:    kind,
+		Msg:     f.redact(fmt.Sprintf(format, args...)),
+		Attempt: f.Attempts,
+		TraceID: f.TraceID,
+	})
+}
@@ -0,0 +1,71 @@
+package fetch
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// isDialError is true for failures before
+// any byte was sent to the server
+func isDialError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return false
+}
+
+// swapWWW turns example.com into www.example.com and vice versa
+func swapWWW(host string) string {
+	if strings.HasPrefix(host, "www.") {
+		return strings.TrimPrefix(host, "www.")
+	}
+	return "www." + host
+}
+
+// wwwFallback retries a request, which failed to connect,
+// on the www-prefixed host - or the apex host.
+// User supplied url lists often have the wrong one.
+func (f *Job) wwwFallback(client *http.Client, err error) (*http.Response, error) {
+
+	if !isDialError(err) || net.ParseIP(f.Req.URL.Hostname()) != nil {
+		return nil, err
+	}
+	if f.Req.Body != nil && f.Req.Body != http.NoBody {
+		if f.Req.GetBody == nil {
+			return nil, err
+		}
+		body, errBody := f.Req.GetBody()
+		if errBody != nil {
+			return nil, err
+		}
+		f.Req.Body = body
+	}
+
+	orig := f.Req.URL.Host
+	host := swapWWW(f.Req.URL.Hostname())
+	if port := f.Req.URL.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	f.Req.URL.Host = host
+	if f.Req.Host == orig {
+		f.Req.Host = ""
+	}
+	resp, err2nd := client.Do(f.Req)
+	if err2nd != nil {
+		f.Req.URL.Host = orig
+		f.log(slog.LevelWarn, "www fallback failed", "host", host, "err", err2nd)
+		return nil, err
+	}
+	f.event("fallback", "host %v substituted by %v after %v", orig, host, err)
+	f.log(slog.LevelInfo, "host substituted", "from", orig, "to", host)
+	return resp, nil
+}
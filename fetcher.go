@@ -1,42 +1,208 @@
 // Package fetches http resources,
 // for appengine and standalone go programmes.
+//
+// Jobs are best built with New() and options:
+//
+//	j := fetch.New("example.com/feed",
+//		fetch.WithTimeout(10*time.Second),
+//		fetch.WithHeaders(http.Header{"Accept": {"application/json"}}),
+//	)
+//	if err := j.Do(); err != nil {
+//		...
+//	}
+//
+// Setting the exported fields of Job directly remains supported.
 package fetch
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"log/slog"
+	"mime"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/zew/util"
 
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/appengine"
 	"google.golang.org/appengine/urlfetch"
 )
 
 var MsgNoRedirects = "redirect cancelled"
 
+var ErrBodyTooLarge = errors.New("response body too large")
+
 type Job struct {
-	URL                 string
-	Req                 *http.Request // holds the final request Url for inspection
-	Timeout             time.Duration
-	OnRedirect          int // 1 => call off upon redirects
-	LogLevel            int
-	ForceProtocol       string
-	ForceHttps          bool          // Force https even on dev server; forgot why we would need this
-	AeReq               *http.Request // Appengine Request - only for getting an AE context
+	URL                string
+	Req                *http.Request  // holds the final request Url for inspection
+	Jar                http.CookieJar // share one jar across jobs for session cookies; see NewJar()
+	Timeout            time.Duration
+	Redirect           RedirectPolicy // which redirects to follow
+	RedirectScope      int            // ScopeDomain, ScopeHost or ScopeOrigin; which hops get credentials
+	SensitiveHeaders   []string       // stripped like Cookie and Authorization on out of scope redirects
+	RedactParams       []string       // query parameters redacted from logged urls, in addition to SecretParams
+	LogLevel           int
+	Logger             Logger     // receives log entries instead of Msg; see WithLogger()
+	Verbosity          *Verbosity // if set, Msg and Events are kept only for failed, slow or sampled fetches
+	ForceProtocol      string
+	ForceHttps         bool             // Force https even on dev server; forgot why we would need this
+	Rewrites           []RewriteRule    // applied to the URL before fetching
+	DevDowngrade       Toggle           // fetch https urls via http; Auto means on the appengine dev server, unless ForceHttps
+	CertFallback       Toggle           // retry GETs via http, if the server answers https in plain http; never upon failed verification; Auto means On
+	HostHeader         string           // sent instead of the url host; also used as TLS server name
+	LocalAddr          string           // source IP or network interface name, for multi homed hosts
+	Proxy              string           // http://, https:// or socks5:// url, optionally with user:password
+	Env                Environment      // empty means detected; cloudrun and functions use a shared, serverless tuned transport
+	MaxBytes           int64            // body size limit; 0 means unlimited
+	MaxBytesByType     map[string]int64 // overrides MaxBytes per media type, i.e. "text/html", "image/*"; -1 means unlimited
+	MaxHeaderBytes     int64            // response header size limit; stdlib default is 1 MB
+	MaxHeaderCount     int              // limit on response header values, 0 means unlimited
+	MaxSetCookies      int              // limit on Set-Cookie headers, 0 means unlimited
+	MaxDecompressRatio float64          // decoded to compressed size; 0 means 200, negative means unchecked
+	MaxDecompressed    int64            // decoded size limit; MaxBytes applies anyway
+	WWWFallback        bool             // on DNS or connect failure, retry example.com as www.example.com and vice versa
+	Mirrors            []string         // alternative urls, tried in order after the url failed all its attempts; see MirrorURL
+	Wayback            bool             // upon 404 or 410, serve the latest snapshot of the Internet Archive; see Archived
+	IdleTimeout        time.Duration    // if > 0, bodies receiving no bytes for this long fail with ErrBodyStalled; unlike Timeout, long downloads are fine
+	Limiter            *Limiter         // per host rate limits; share across jobs
+	Stream             bool             // do not read the body; the caller reads and closes Body()
+	CopyBuffer         int              // buffer size for FetchTo(); default 32 KB
+	Resume             bool             // DownloadFile: continue a partial download with a range request
+	Sniff              bool             // detect gzip and JSON bodies by content, ignoring mislabeled headers
+	ReportText         bool             // detect charset and natural language of text responses into Text
+
+	// Retries - with MaxAttempts > 1 - on transient network errors and RetryStatus.
+	// Backoff doubles from BackoffBase up to BackoffCap;
+	// Jitter between 0 and 1 randomly shortens each wait by up to this fraction.
+	MaxAttempts    int
+	BackoffBase    time.Duration // default 500ms
+	BackoffCap     time.Duration // default 30s
+	Jitter         float64
+	Storm          *StormGuard      // shared across jobs; spreads retry bursts against one host
+	Breaker        *Breaker         // shared across jobs; fails fast against dead hosts
+	RetryStatus    []int            // nil means 429, 502, 503, 504
+	TerminalStatus []int            // never retried, even if in RetryStatus
+	OnChallenge    ChallengeHandler // called upon anti-bot challenges, before retrying
+
+	// Request cache directives; sent as Cache-Control.
+	NoCache      bool          // force revalidation with the origin
+	OnlyIfCached bool          // accept cached content only; intermediaries answer 504 otherwise
+	MaxStale     time.Duration // accept stale content up to this age; -1 for any age
+	RangeCache   *RangeCache   // serves and stores partial content of range requests
+	Cache        Cache         // serves fresh responses, revalidates stale ones per RFC 7234; see NewMemoryCache
+
+	// Conditional GET; a 304 answer sets NotModified and carries no body.
+	// See Revalidate().
+	IfModifiedSince time.Time
+	IfNoneMatch     string // an ETag
+
+	// HeadFirst issues a HEAD request first, and skips the GET, if the resource is
+	// larger than MaxBytes, not of AcceptTypes, or not modified since Mod of a previous fetch.
+	// A skipped GET sets Skipped and fails with ErrSkipped or ErrBodyTooLarge.
+	HeadFirst   bool
+	AcceptTypes []string // media types, i.e. "application/pdf"; "text/*" allowed
+
+	Scorecards  *Scorecards   // if set, every fetch is recorded
+	DryRun      bool          // prepare everything, but do not send; see Wire
+	Watchdog    time.Duration // if > 0, fetches exceeding Timeout by this margin are dumped and cancelled
+	DomainStats *DomainStats  // if set, every fetch is recorded; see Export()
+	Dedup       *Dedup        // if set, duplicate pages are marked in DuplicateOf
+	Metrics     *Metrics      // if set, every fetch is exported to Prometheus; see WithMetrics()
+	Tracer      trace.Tracer  // if set, spans per fetch and attempt; see WithTracing()
+	TraceID     string        // shared by all attempts, hops and fallbacks of a fetch; in events, logs, metric exemplars and results
+
+	// Bodies larger than SpillThreshold - or of unknown length - are written
+	// to a file in SpillDir instead of memory; see ReadSpill().
+	// With SpillKeys, spill files are AES-GCM encrypted.
+	SpillDir       string
+	SpillThreshold int64
+	SpillKeys      KeyProvider
+
+	Scrubber *Scrubber     // applied to bodies before they are persisted
+	AeReq    *http.Request // Appengine Request - only for getting an AE context
+	Headers  http.Header   // added to the request, whether built from URL or prebuilt
+
+	// SecretHeaders maps request header names to secret names.
+	// Secrets are resolved on every Fetch() - not at construction time -
+	// so rotated tokens are picked up by long running callers.
+	SecretHeaders map[string]string
+	Secrets       Secrets // defaults to EnvSecrets
+
+	Schema       *jsonschema.Schema // if set, 2xx responses are validated; violations yield a *SchemaError
+	Fingerprints []Fingerprint      // 2xx responses matching any are failures with a *SoftError; see DefaultFingerprints
+
 	the_response_fields string
 	Status              int
-	bts                 []byte // lowercase, excluded from json dump
-	BtsDump             string // upper case, is set to an ellipsoid of full sized bts
+	Redirects           []RedirectHop
+	Addrs               []ResolvedAddr // DNS results; the one connected to is marked
+	CDN                 *CDNInfo       // nil, unless CDN debug headers were found
+	TLS                 *TLSInfo       // nil for plain http
+	Archived            *ArchiveInfo   // Wayback: the body is an archived snapshot
+	MirrorURL           string         // Mirrors: the url which answered
+	RespHeader          http.Header    // response header
+	bts                 []byte         // lowercase, excluded from json dump
+	BtsDump             string         // upper case, is set to an ellipsoid of full sized bts
+	SpillPath           string         // file holding the body, if spilled
+	BytesOnWire         int64          // body bytes received, compressed
+	BytesDecoded        int64          // body bytes after decompression
+	Encoding            string         // content encoding of the body as received; empty for identity
+	Wire                string         // DryRun: the request as it would have been sent
+	Skipped             string         // why the job or its GET was skipped
+	SniffedType         string         // Sniff: the content type detected from the body
+	DuplicateOf         string         // Dedup: the first url of identical or near identical content
+	Text                *TextReport    // ReportText: charset and language
 	Mod                 time.Time
+	ETag                string // validator for IfNoneMatch of the next fetch
+	NotModified         bool   // status 304 upon IfModifiedSince or IfNoneMatch
+	FromCache           bool   // Cache: served from the cache, without a request
+	Revalidated         bool   // Cache: served from the cache, after a 304 of the server
+	CacheMiss           bool   // Cache: OnlyIfCached found nothing usable; Status is 504
+	Elapsed             time.Duration
+	Attempts            int
+	RetryAfter          time.Duration // the last wait demanded by the server via Retry-After
+	Diag                *Diagnostics  // environment snapshot, taken on error
+	Timings             Timings
 	Msg                 string
+	Events              []Event
+	Trimmed             bool // Msg and Events were dropped per Verbosity
 	Err                 error
+	Kind                ErrorKind // bucket of Err or Status; see Classify()
+
+	started             time.Time
+	requested, received time.Time // of the final response; for freshness
+	decoding            string    // content encoding we decode ourselves
+	stream              *bodyStream
+	primary             *url.URL            // Mirrors: the original request url
+	mirror              int                 // Mirrors: index of the next one
+	attemptBase         int                 // Attempts before the current mirror
+	rewritten           bool                // Rewrites: applied to the current url
+	traceGenerated      bool                // TraceID was not set by the caller
+	cached              *CachedResponse     // Cache: the stale response being revalidated
+	breakerHost         string              // Breaker: host admitted for the current attempt
+	breakerProbe        bool                // Breaker: the attempt is the half-open probe
+	slotHost            string              // Batch: host of the MaxInFlightPerHost slot held
+	callMaxBytes        int64               // MustFetch: limit of the current call
+	origHeader          http.Header         // Result: headers of a prebuilt Req before the first fetch
+	attemptHeaders      map[string][]string // request headers of the current attempt only; the values before
+
+	clientKind string
+	client     *http.Client
+	localAddr  string // our side of the last connection
+
+	authz       string // Authorization value; never dumped
+	authzScheme string
+	digestUser  string
+	digestPass  string
+
+	onRetry func(f *Job, reason string, wait time.Duration) // set by Batch
 }
 
 // See bts, BtsDump of Job struct
@@ -44,6 +210,32 @@ func (j *Job) Bytes() []byte {
 	return j.bts
 }
 
+// ContentType is the media type of the response,
+// without parameters such as charset; lower case.
+func (j *Job) ContentType() string {
+	if j.RespHeader == nil {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(j.RespHeader.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mt
+}
+
+// ContentLength as announced by the server; -1 if unknown.
+// Compressed responses announce their compressed size.
+func (j *Job) ContentLength() int64 {
+	if j.RespHeader == nil {
+		return -1
+	}
+	cl, err := strconv.ParseInt(j.RespHeader.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return cl
+}
+
 // Since json.MarshallIndent cannot
 // print http.request channel,
 // we have to provide our own stringer
@@ -53,8 +245,12 @@ func (j Job) String() string {
 	if j.Err != nil {
 		ret += fmt.Sprintf("error was: %v\n", j.Err) // json.MarshallIndent also fails to render certain errors :(
 	}
-	ret += fmt.Sprintf("   Req %v\n", j.Req.URL)
-	ret += fmt.Sprintf("ae Req %v\n", j.AeReq.URL)
+	if j.Req != nil {
+		ret += fmt.Sprintf("   Req %v\n", j.redactURL(j.Req.URL.String()))
+	}
+	if j.AeReq != nil {
+		ret += fmt.Sprintf("ae Req %v\n", j.redactURL(j.AeReq.URL.String()))
+	}
 	j.Req, j.AeReq = nil, nil
 	j.BtsDump = util.Ellipsoider(string(j.bts), 800)
 	ret += util.IndentedDump(&j)
@@ -80,20 +276,78 @@ Use the custom String() method.`
 // UrlGetter universal http getter for app engine and standalone go programs.
 // Previously response was returned. Forgot why. Dropped it.
 func (f *Job) Fetch() {
+	ctx := context.Background()
+	if f.Req != nil {
+		ctx = f.Req.Context()
+	}
+	f.FetchContext(ctx)
+}
+
+// Do is Fetch() returning the error,
+// so that it can not be forgotten.
+// j.Err is set nevertheless.
+func (j *Job) Do() error {
+	j.Fetch()
+	return j.Err
+}
+
+// DoContext is Do() with a context; see FetchContext().
+func (j *Job) DoContext(ctx context.Context) error {
+	j.FetchContext(ctx)
+	return j.Err
+}
+
+// FetchContext is Fetch() with cancellation and deadlines
+// propagated from ctx - i.e. from an upstream handler.
+// The deadline of ctx applies in addition to Timeout.
+// With MaxAttempts > 1, transient failures are retried.
+func (f *Job) FetchContext(ctx context.Context) {
+	f.started = time.Now()
+	f.saveCallerHeader()
+	f.Attempts, f.RetryAfter = 0, 0
+	f.resetMirrors()
+	f.Trimmed = false
+	f.Metrics.start()
+	ctx, endSpan := f.startSpan(ctx)
+	defer endSpan()
+	f.assignTraceID(ctx)
+	defer f.finish()
+	for {
+		f.Attempts++
+		actx, endAttempt := f.startAttemptSpan(ctx)
+		f.fetchOnce(actx)
+		endAttempt()
+		f.recordBreaker()
+		wait, ok := f.retryAfterAttempt(ctx)
+		if !ok {
+			if f.nextMirror(ctx) {
+				continue
+			}
+			break
+		}
+		if !sleepContext(ctx, wait) {
+			return
+		}
+	}
+	f.recordMirror()
+	f.waybackFallback(ctx)
+}
+
+// fetchOnce is a single attempt
+func (f *Job) fetchOnce(ctx context.Context) {
 
 	var err error
 	httpsCause := false
+	f.Redirects = nil
 
 	if f.Timeout == 0 {
 		f.Timeout = 35
 	}
 
-	if f.LogLevel > 0 {
-		if f.Req != nil {
-			f.Msg += fmt.Sprintf("orig req url: %v\n", f.Req.URL.String())
-		} else {
-			f.Msg += fmt.Sprintf("orig str url: %v\n", f.URL)
-		}
+	if f.Req != nil {
+		f.log(slog.LevelDebug, "orig req url", "url", f.Req.URL)
+	} else {
+		f.log(slog.LevelDebug, "orig str url", "url", f.URL)
 	}
 
 	//
@@ -117,15 +371,21 @@ func (f *Job) Fetch() {
 		}
 	}
 
+	f.Err = f.rewrite()
+	if f.Err != nil {
+		return
+	}
+
 	if f.Req.URL.Path == "" {
 		f.Req.URL.Path = "/"
 	}
+	f.Req = f.Req.WithContext(ctx)
 
 	if len(f.ForceProtocol) > 1 {
 		f.ForceProtocol = strings.TrimSuffix(f.ForceProtocol, ":")
 		if f.ForceProtocol == "http" || f.ForceProtocol == "https" {
 			f.Req.URL.Scheme = f.ForceProtocol
-			f.Msg += fmt.Sprintf("Forcing protocol %q\n", f.ForceProtocol)
+			f.log(slog.LevelInfo, "forcing protocol", "protocol", f.ForceProtocol)
 		}
 	}
 
@@ -134,112 +394,175 @@ func (f *Job) Fetch() {
 	client := util.HttpClient()
 
 	// We could use logx.IsAppengine()
-	var ctx context.Context // try appengine ...
+	var aeCtx context.Context // try appengine ...
 	if f.AeReq != nil {
 		func() {
 			defer func() {
-				rec := recover()
-				f.Msg += fmt.Sprintf("appengine panic: %v\n", rec)
+				if rec := recover(); rec != nil {
+					f.log(slog.LevelError, "appengine panic", "recovered", rec)
+				}
 			}()
-			ctx = appengine.NewContext(f.AeReq)
+			aeCtx = appengine.NewContext(f.AeReq)
 		}()
 	}
-	if f.AeReq == nil || ctx == nil {
+	if f.AeReq == nil || aeCtx == nil {
 		client.Timeout = time.Duration(f.Timeout * time.Second) // GAE does not allow that long
-		f.Msg += fmt.Sprintf("standard  client\n")
+		f.log(slog.LevelDebug, "standard client")
+		f.clientKind = "standard"
+		var tr http.RoundTripper
+		tr, f.Err = f.transport()
+		if f.Err != nil {
+			return
+		}
+		if tr != nil {
+			client.Transport = tr
+		}
 	} else {
-		client = urlfetch.Client(ctx)
-		f.Msg += fmt.Sprintf("appengine client\n")
+		client = urlfetch.Client(aeCtx)
+		f.clientKind = "urlfetch"
+		f.log(slog.LevelDebug, "appengine client")
 
 		// this does not prevent urlfetch: SSL_CERTIFICATE_ERROR
 		// it merely leads to err = "DEADLINE_EXCEEDED"
-		tr := urlfetch.Transport{Context: ctx, AllowInvalidServerCertificate: true}
+		tr := urlfetch.Transport{Context: aeCtx, AllowInvalidServerCertificate: true}
 		// thus
-		tr = urlfetch.Transport{Context: ctx, AllowInvalidServerCertificate: false}
+		tr = urlfetch.Transport{Context: aeCtx, AllowInvalidServerCertificate: false}
 		// tr.Deadline = f.Timeout * time.Second // only possible on aeOld
 		client.Transport = &tr
 		client.Timeout = f.Timeout * time.Second // also not in google.golang.org/appengine/urlfetch
+	}
 
-		// appengine dev server => always fallback to http
-		if appengine.IsDevAppServer() && !f.ForceHttps {
-			f.Req.URL.Scheme = "http"
-		}
+	// appengine dev server => always fallback to http
+	if f.devDowngrade() && f.Req.URL.Scheme == "https" {
+		f.Req.URL.Scheme = "http"
+		f.event("devserver", "downgraded to http")
 	}
 
-	if f.LogLevel > 0 {
-		f.Msg += fmt.Sprintf("url standardized to %v\n", f.Req.URL.String())
+	f.log(slog.LevelDebug, "url standardized", "url", f.Req.URL)
+
+	if f.HostHeader != "" {
+		f.Req.Host = f.HostHeader
+		f.log(slog.LevelInfo, "host header", "host", f.HostHeader)
 	}
 
-	if f.OnRedirect == 1 {
-		redirectHandler := func(req *http.Request, via []*http.Request) error {
-			if len(via) == 1 && req.URL.Path == via[0].URL.Path+"/" {
-				// allow redirect from /gesundheit to /gesundheit/
-				return nil
-			}
-			spath := "\n"
-			for _, v := range via {
-				spath += v.URL.Path + "\n"
-			}
-			spath += req.URL.Path + "\n"
-			return fmt.Errorf("%v %v", MsgNoRedirects, spath)
+	f.restoreAttemptHeaders()
+	f.setHeaders()
+	f.injectTraceparent(ctx)
+	f.setAuth()
+	f.setCacheControl()
+	f.setConditional()
+	f.requestCompression()
+
+	f.Err = f.injectSecretHeaders()
+	if f.Err != nil {
+		return
+	}
+
+	f.client = client
+	client.CheckRedirect = f.checkRedirect
+	if f.Jar != nil {
+		client.Jar = f.Jar
+	}
+
+	stopWatchdog := f.startWatchdog()
+	defer stopWatchdog()
+
+	if f.DryRun {
+		f.Err = f.dryRun()
+		return
+	}
+
+	if f.RangeCache != nil && !f.NoCache && f.RangeCache.serve(f) {
+		return
+	}
+
+	if f.lookupCache() {
+		return
+	}
+
+	f.Err = f.checkBreaker()
+	if f.Err != nil {
+		return
+	}
+
+	f.Err = f.waitLimiter()
+	if f.Err != nil {
+		return
+	}
+
+	tr := f.attachTrace()
+	defer tr.collect(f)
+
+	if f.HeadFirst && f.Req.Method == "GET" {
+		if skip := f.headCheck(client); skip {
+			return
 		}
-		client.CheckRedirect = redirectHandler
 	}
 
 	// The actual call
 	// =============================
+	f.requested = time.Now()
 	resp, err := client.Do(f.Req)
+	if err != nil {
+		resp, err = f.retryReusedConn(client, tr, err)
+	}
+	if err != nil && f.WWWFallback {
+		resp, err = f.wwwFallback(client, err)
+	}
+	if err != nil {
+		err = classify(f.wrapHeaderLimit(err))
+	}
 
 	if err != nil {
 
-		if f.OnRedirect == 1 { // Handle redirect error case
-			if strings.Contains(err.Error(), MsgNoRedirects) {
+		if f.Redirect.Refuse || f.Redirect.SameHost { // Handle redirect error case
+			if errors.Is(err, ErrRedirectBlocked) {
 				f.Mod = time.Now().Add(-10 * time.Minute)
-				f.Msg += "First call failed due to redirect\n"
+				f.log(slog.LevelWarn, "first call failed due to redirect")
 				f.Err = err
 				return
 			}
 		}
 
 		// Under narrow conditions => fallback to http
-		httpsCause = httpsCause || strings.Contains(err.Error(), "SSL_CERTIFICATE_ERROR")
-		httpsCause = httpsCause || strings.Contains(err.Error(), "tls: oversized record received with length")
+		httpsCause = httpsCause || plainHTTPServer(err)
 
 		if httpsCause && f.Req.URL.Scheme == "https" && f.Req.Method == "POST" {
 			// We cannot do a fallback for a post request -
 			// the r.Body.Reader is consumed
-			f.Msg += "Cannot do https requests. Possible reason: Dev server\n"
+			f.log(slog.LevelWarn, "cannot do https requests; possible reason: dev server")
 			if strings.Contains(
 				err.Error(),
 				"net/http: Client Transport of type init.failingTransport doesn't support CancelRequest; Timeout not supported",
 			) {
-				f.Msg += "Did you forget to submit the AE Request?\n"
+				f.log(slog.LevelWarn, "did you forget to submit the AE request?")
 			}
 			f.Err = err
 			return
 		}
 
-		if httpsCause && f.Req.URL.Scheme == "https" && f.Req.Method == "GET" {
+		if httpsCause && f.Req.URL.Scheme == "https" && f.Req.Method == "GET" && f.certFallback() {
 			f.Req.URL.Scheme = "http"
+			f.event("fallback", "https failed with %v; trying http", err)
 			var err2nd error
 			resp, err2nd = client.Do(f.Req)
+			err2nd = classify(err2nd)
 			// while protocol http may go through
 			// next obstacle might be - again - a redirect error:
 			if err2nd != nil {
-				if f.OnRedirect == 1 { // Handle redirect error case
-					if strings.Contains(err2nd.Error(), MsgNoRedirects) {
+				if f.Redirect.Refuse || f.Redirect.SameHost { // Handle redirect error case
+					if errors.Is(err2nd, ErrRedirectBlocked) {
 						f.Mod = time.Now().Add(-10 * time.Minute)
-						f.Msg += "GET fallback failed due to redirect\n"
+						f.log(slog.LevelWarn, "GET fallback failed due to redirect")
 						f.Err = err2nd
 						return
 					}
 				}
-				f.Msg += fmt.Sprintf("GET fallback to http failed with %v\n", err2nd)
+				f.log(slog.LevelWarn, "GET fallback to http failed", "err", err2nd)
 				f.Err = err
 				return
 			}
-			f.Msg += fmt.Sprintf("\tsuccessful fallback to http %v", f.Req.URL.String())
-			f.Msg += fmt.Sprintf("\tafter %v\n", err)
+			f.log(slog.LevelWarn, "successful fallback to http", "url", f.Req.URL, "after", err)
 			err = nil // CLEAR error
 		}
 	}
@@ -249,6 +572,11 @@ func (f *Job) Fetch() {
 		return
 	}
 
+	resp, f.Err = f.digestRetry(client, resp)
+	if f.Err != nil {
+		return
+	}
+
 	//
 	// We got response, but
 	// explicit bad response from server
@@ -257,29 +585,77 @@ func (f *Job) Fetch() {
 		return
 	}
 
+	f.received = time.Now()
 	f.Status = resp.StatusCode
+	f.RespHeader = resp.Header
+	f.CDN = parseCDN(resp.Header)
+	f.TLS = tlsInfo(resp)
+	f.observeSkew()
 
-	f.bts, f.Err = ioutil.ReadAll(resp.Body)
+	f.Err = f.checkHeaderLimits(resp.Header)
 	if f.Err != nil {
+		resp.Body.Close()
 		return
 	}
+
+	f.watchIdle(resp)
+
+	if f.Stream {
+		f.Mod = lastModified(resp.Header)
+		f.Err = f.openStream(resp)
+		return
+	}
+
 	defer resp.Body.Close()
+	f.Err = bodyReadError(f.readBody(resp))
+	if f.Err != nil {
+		return
+	}
+	f.checkCompression()
+	if f.RangeCache != nil {
+		f.RangeCache.store(f, resp)
+	}
+	f.mergeNotModified()
+	if f.Sniff {
+		f.Err = f.sniffBody()
+		if f.Err != nil {
+			return
+		}
+	}
+
+	if f.ReportText {
+		f.reportText()
+	}
 
 	// time stamp
-	var tlm time.Time // time last modified
-	lm := resp.Header.Get("Last-Modified")
-	if lm != "" {
-		tlm, err = time.Parse(time.RFC1123, lm) // Last-Modified: Sat, 29 Aug 2015 21:15:39 GMT
-		if err != nil {
-			tlm, err = time.Parse(time.RFC1123Z, lm) // with numeric time zone
-			if err != nil {
-				var zeroTime time.Time
-				tlm = zeroTime
-			}
-		}
+	f.Mod = lastModified(f.RespHeader)
+	f.checkNotModified(f.RespHeader)
+
+	f.Err = f.checkSoftError()
+	if f.Err != nil {
+		return
+	}
+	f.Err = f.validateSchema()
+	if f.Err != nil {
+		return
 	}
-	f.Mod = tlm
+	f.storeCache()
 
-	return
+}
 
+// lastModified parses the Last-Modified header;
+// zero time if absent or invalid.
+func lastModified(h http.Header) time.Time {
+	lm := h.Get("Last-Modified")
+	if lm == "" {
+		return time.Time{}
+	}
+	tlm, err := time.Parse(time.RFC1123, lm) // Last-Modified: Sat, 29 Aug 2015 21:15:39 GMT
+	if err != nil {
+		tlm, err = time.Parse(time.RFC1123Z, lm) // with numeric time zone
+		if err != nil {
+			return time.Time{}
+		}
+	}
+	return tlm
 }
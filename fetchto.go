@@ -0,0 +1,37 @@
+package fetch
+
+import (
+	"io"
+
+	"golang.org/x/net/context"
+)
+
+// FetchTo copies the response body into w -
+// a file, a hash, a pipe - without buffering it in the job.
+// Only 2xx bodies are copied.
+func (j *Job) FetchTo(w io.Writer) (int64, error) {
+	return j.FetchToContext(context.Background(), w)
+}
+
+// FetchToContext is FetchTo with a context.
+func (j *Job) FetchToContext(ctx context.Context, w io.Writer) (int64, error) {
+	j.Stream = true
+	j.FetchContext(ctx)
+	if j.Err != nil {
+		return 0, j.Err
+	}
+	body := j.Body()
+	defer body.Close()
+	if j.Status < 200 || j.Status > 299 {
+		return 0, j.StatusErr()
+	}
+	size := j.CopyBuffer
+	if size <= 0 {
+		size = 32 * 1024
+	}
+	n, err := io.CopyBuffer(w, body, make([]byte, size))
+	if err != nil {
+		j.Err = err
+	}
+	return n, err
+}
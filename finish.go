@@ -0,0 +1,44 @@
+package fetch
+
+import (
+	"time"
+)
+
+// Timings break down Elapsed.
+// DNS to Download refer to the final request;
+// they are zero for reused connections or IP literals, where no lookup
+// or handshake happened, and Download is zero for streamed bodies.
+type Timings struct {
+	Redirects time.Duration // until the last redirect response
+	Final     time.Duration // the final request, including body download
+
+	DNS      time.Duration
+	Connect  time.Duration // TCP
+	TLS      time.Duration // handshake
+	TTFB     time.Duration // request written until first response byte - the server side
+	Download time.Duration // first response byte until body read
+}
+
+// finish runs after every fetch, after all other deferred funcs
+func (f *Job) finish() {
+	f.Elapsed = time.Since(f.started)
+	f.Timings.Redirects = f.redirectTime()
+	f.Timings.Final = f.Elapsed - f.Timings.Redirects
+	f.Msg = f.redact(f.Msg)
+	f.Err = f.redactErr(classify(f.Err))
+	f.Kind = f.Classify()
+	f.dedup()
+	f.Diag = nil
+	if f.Err != nil {
+		f.Diag = f.diagnostics()
+	}
+	f.recordQuota()
+	f.Metrics.record(f)
+	if f.Scorecards != nil {
+		f.Scorecards.Record(f)
+	}
+	if f.DomainStats != nil {
+		f.DomainStats.Record(f)
+	}
+	f.trimDiagnostics()
+}
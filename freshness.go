@@ -0,0 +1,130 @@
+package fetch
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClockSkews estimates, per host, how far the server clock
+// is ahead of ours - from the Date headers of its responses.
+// Freshness calculations subtract the skew,
+// so that neither a drifting server nor a drifting local clock
+// leads to premature or late revalidation.
+type ClockSkews struct {
+	mu    sync.Mutex
+	hosts map[string]time.Duration
+}
+
+// Skews is shared by all jobs.
+var Skews = &ClockSkews{}
+
+// Observe a response. Date has a resolution of one second,
+// thus single observations are smoothed.
+func (c *ClockSkews) Observe(host string, date, requested, received time.Time) {
+	if host == "" || date.IsZero() || received.Before(requested) {
+		return
+	}
+	mid := requested.Add(received.Sub(requested) / 2)
+	skew := date.Add(500 * time.Millisecond).Sub(mid) // Date is truncated
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hosts == nil {
+		c.hosts = map[string]time.Duration{}
+	}
+	prev, ok := c.hosts[host]
+	if !ok {
+		c.hosts[host] = skew
+		return
+	}
+	c.hosts[host] = prev + (skew-prev)/5
+}
+
+// Get returns the estimated skew; positive if the server is ahead.
+func (c *ClockSkews) Get(host string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hosts[host]
+}
+
+// observeSkew - a cache serving a stored response sends its Date,
+// set by the origin Age seconds ago
+func (f *Job) observeSkew() {
+	date, err := http.ParseTime(f.RespHeader.Get("Date"))
+	if err != nil {
+		return
+	}
+	if age, err := strconv.ParseInt(f.RespHeader.Get("Age"), 10, 64); err == nil && age > 0 {
+		date = date.Add(time.Duration(age) * time.Second)
+	}
+	Skews.Observe(f.Req.URL.Hostname(), date, f.requested, f.received)
+}
+
+// maxAge of the response Cache-Control; ok is false if absent
+func maxAge(h http.Header) (time.Duration, bool) {
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.TrimSpace(strings.ToLower(d))
+		if strings.HasPrefix(d, "max-age=") {
+			secs, err := strconv.ParseInt(strings.Trim(d[8:], `"`), 10, 64)
+			if err == nil {
+				return time.Duration(secs) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Freshness returns the freshness lifetime and the current age
+// of the response per RFC 7234 section 4.2;
+// the response is fresh while age < lifetime.
+// The Date header is corrected by the estimated skew of the host.
+// Without explicit expiration, the lifetime is 10% of the time
+// since Last-Modified.
+func (j *Job) Freshness() (lifetime, age time.Duration) {
+	if j.RespHeader == nil || j.received.IsZero() {
+		return 0, 0
+	}
+	h := j.RespHeader
+	serverDate, errDate := http.ParseTime(h.Get("Date"))
+	date := j.received
+	if errDate == nil {
+		date = serverDate
+		if j.Req != nil {
+			date = serverDate.Add(-Skews.Get(j.Req.URL.Hostname()))
+		}
+	}
+
+	// Expires and Last-Modified come from the server clock, like Date
+	if ma, ok := maxAge(h); ok {
+		lifetime = ma
+	} else if exp, err := http.ParseTime(h.Get("Expires")); err == nil && errDate == nil {
+		lifetime = exp.Sub(serverDate)
+	} else if lm, err := http.ParseTime(h.Get("Last-Modified")); err == nil && errDate == nil {
+		lifetime = serverDate.Sub(lm) / 10
+	}
+	if lifetime < 0 {
+		lifetime = 0
+	}
+
+	apparent := j.received.Sub(date)
+	if apparent < 0 {
+		apparent = 0
+	}
+	ageValue, _ := strconv.ParseInt(h.Get("Age"), 10, 64)
+	corrected := time.Duration(ageValue)*time.Second + j.received.Sub(j.requested)
+	initial := apparent
+	if corrected > initial {
+		initial = corrected
+	}
+	age = initial + time.Since(j.received)
+	return lifetime, age
+}
+
+// Fresh tells whether the response may still be used without revalidation.
+func (j *Job) Fresh() bool {
+	lifetime, age := j.Freshness()
+	return age < lifetime
+}
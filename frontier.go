@@ -0,0 +1,114 @@
+package fetch
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// SeenSet remembers URLs for a Frontier.
+type SeenSet interface {
+	// Add reports whether u was seen before, and remembers it.
+	Add(u string) bool
+	// Has reports whether u was seen before, without remembering it.
+	Has(u string) bool
+}
+
+// exactSeen remembers every URL; fine for up to some millions.
+type exactSeen map[string]struct{}
+
+func (s exactSeen) Add(u string) bool {
+	if _, ok := s[u]; ok {
+		return true
+	}
+	s[u] = struct{}{}
+	return false
+}
+
+func (s exactSeen) Has(u string) bool {
+	_, ok := s[u]
+	return ok
+}
+
+// Frontier queues URLs for a crawl, each one only once.
+// Hosts take turns, so that no single host dominates.
+// Safe for concurrent use.
+type Frontier struct {
+	mu     sync.Mutex
+	seen   SeenSet
+	queues map[string][]string // by host
+	hosts  []string            // round robin order
+	next   int
+	size   int
+}
+
+// NewFrontier with an exact seen-set, unless seen is given;
+// see NewBloomSeen for huge crawls.
+func NewFrontier(seen SeenSet) *Frontier {
+	if seen == nil {
+		seen = exactSeen{}
+	}
+	return &Frontier{seen: seen, queues: map[string][]string{}}
+}
+
+// frontierKey normalizes for the seen-set: without fragment, lower case host
+func frontierKey(raw string) (key, host string, ok bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = strings.ToLower(u.Host)
+	return u.String(), u.Hostname(), true
+}
+
+// Add queues the URLs not seen before;
+// it returns how many were queued.
+func (fr *Frontier) Add(urls ...string) int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	added := 0
+	for _, raw := range urls {
+		key, host, ok := frontierKey(raw)
+		if !ok || fr.seen.Add(key) {
+			continue
+		}
+		if _, ok := fr.queues[host]; !ok {
+			fr.hosts = append(fr.hosts, host)
+		}
+		fr.queues[host] = append(fr.queues[host], key)
+		fr.size++
+		added++
+	}
+	return added
+}
+
+// Next dequeues the next URL, taking hosts in turn.
+func (fr *Frontier) Next() (string, bool) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	for len(fr.hosts) > 0 {
+		if fr.next >= len(fr.hosts) {
+			fr.next = 0
+		}
+		host := fr.hosts[fr.next]
+		q := fr.queues[host]
+		if len(q) == 0 {
+			delete(fr.queues, host)
+			fr.hosts = append(fr.hosts[:fr.next], fr.hosts[fr.next+1:]...)
+			continue
+		}
+		fr.queues[host] = q[1:]
+		fr.next++
+		fr.size--
+		return q[0], true
+	}
+	return "", false
+}
+
+// Len is the number of queued URLs.
+func (fr *Frontier) Len() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.size
+}
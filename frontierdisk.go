@@ -0,0 +1,300 @@
+package fetch
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// HostQueue is the state of one host of a DiskFrontier.
+type HostQueue struct {
+	Host     string `json:"-"`
+	Len      int    `json:"-"`
+	Paused   bool
+	Priority int // higher is served first; hosts of equal priority take turns
+}
+
+var (
+	bucketSeen  = []byte("seen")
+	bucketHosts = []byte("hosts")
+)
+
+func queueBucket(host string) []byte {
+	return []byte("q:" + host)
+}
+
+// DiskFrontier is a Frontier in a bolt file, sharded by host,
+// so that crawls over days survive restarts.
+// Each host's queue can be paused, dropped or re-prioritized.
+// The seen-set is kept exactly on disk;
+// an optional in-memory SeenSet in front saves lookups.
+type DiskFrontier struct {
+	db   *bolt.DB
+	seen SeenSet
+
+	mu    sync.Mutex
+	hosts map[string]*HostQueue
+	next  int
+}
+
+// OpenDiskFrontier opens or creates the frontier at path.
+func OpenDiskFrontier(path string, seen SeenSet) (*DiskFrontier, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	fr := &DiskFrontier{db: db, seen: seen, hosts: map[string]*HostQueue{}}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketSeen); err != nil {
+			return err
+		}
+		hb, err := tx.CreateBucketIfNotExists(bucketHosts)
+		if err != nil {
+			return err
+		}
+		return hb.ForEach(func(k, v []byte) error {
+			hq := &HostQueue{}
+			if err := json.Unmarshal(v, hq); err != nil {
+				return fmt.Errorf("frontier host %s: %w", k, err)
+			}
+			hq.Host = string(k)
+			if qb := tx.Bucket(queueBucket(hq.Host)); qb != nil {
+				hq.Len = qb.Stats().KeyN
+			}
+			fr.hosts[hq.Host] = hq
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return fr, nil
+}
+
+// Close the file.
+func (fr *DiskFrontier) Close() error {
+	return fr.db.Close()
+}
+
+func putHost(tx *bolt.Tx, hq *HostQueue) error {
+	bts, err := json.Marshal(hq)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketHosts).Put([]byte(hq.Host), bts)
+}
+
+// Add queues the URLs not seen before;
+// it returns how many were queued.
+func (fr *DiskFrontier) Add(urls ...string) (int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	added := map[string]int{}
+	keys := []string{}
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		sb := tx.Bucket(bucketSeen)
+		for _, raw := range urls {
+			key, host, ok := frontierKey(raw)
+			if !ok {
+				continue
+			}
+			if fr.seen != nil && fr.seen.Has(key) {
+				continue
+			}
+			if sb.Get([]byte(key)) != nil {
+				keys = append(keys, key) // seen in an earlier run
+				continue
+			}
+			if err := sb.Put([]byte(key), []byte{}); err != nil {
+				return err
+			}
+			qb, err := tx.CreateBucketIfNotExists(queueBucket(host))
+			if err != nil {
+				return err
+			}
+			seq, _ := qb.NextSequence()
+			k := make([]byte, 8)
+			binary.BigEndian.PutUint64(k, seq)
+			if err := qb.Put(k, []byte(key)); err != nil {
+				return err
+			}
+			if _, ok := fr.hosts[host]; !ok && added[host] == 0 {
+				if err := putHost(tx, &HostQueue{Host: host}); err != nil {
+					return err
+				}
+			}
+			added[host]++
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// only now; a failed commit must not leave keys seen, but never queued
+	if fr.seen != nil {
+		for _, key := range keys {
+			fr.seen.Add(key)
+		}
+	}
+	total := 0
+	for host, n := range added {
+		hq, ok := fr.hosts[host]
+		if !ok {
+			hq = &HostQueue{Host: host}
+			fr.hosts[host] = hq
+		}
+		hq.Len += n
+		total += n
+	}
+	return total, nil
+}
+
+// ready lists hosts with queued URLs and not paused, by priority
+func (fr *DiskFrontier) ready() []*HostQueue {
+	ready := []*HostQueue{}
+	for _, hq := range fr.hosts {
+		if hq.Len > 0 && !hq.Paused {
+			ready = append(ready, hq)
+		}
+	}
+	sort.Slice(ready, func(i, k int) bool {
+		if ready[i].Priority != ready[k].Priority {
+			return ready[i].Priority > ready[k].Priority
+		}
+		return ready[i].Host < ready[k].Host
+	})
+	return ready
+}
+
+// Next dequeues the next URL: from the hosts of the highest priority,
+// taking them in turn. Paused hosts are passed over.
+func (fr *DiskFrontier) Next() (string, bool, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	for {
+		ready := fr.ready()
+		if len(ready) == 0 {
+			return "", false, nil
+		}
+		top := 1
+		for top < len(ready) && ready[top].Priority == ready[0].Priority {
+			top++
+		}
+		hq := ready[fr.next%top]
+		fr.next++
+
+		u := ""
+		err := fr.db.Update(func(tx *bolt.Tx) error {
+			qb := tx.Bucket(queueBucket(hq.Host))
+			if qb == nil {
+				return nil
+			}
+			c := qb.Cursor()
+			k, v := c.First()
+			if k == nil {
+				return nil
+			}
+			u = string(v)
+			return c.Delete()
+		})
+		if err != nil {
+			return "", false, err
+		}
+		if u == "" {
+			hq.Len = 0 // out of sync; try the others
+			continue
+		}
+		hq.Len--
+		return u, true, nil
+	}
+}
+
+// Len is the number of queued URLs, including those of paused hosts.
+func (fr *DiskFrontier) Len() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	n := 0
+	for _, hq := range fr.hosts {
+		n += hq.Len
+	}
+	return n
+}
+
+// Hosts lists the state of all hosts, by priority.
+func (fr *DiskFrontier) Hosts() []HostQueue {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	ret := make([]HostQueue, 0, len(fr.hosts))
+	for _, hq := range fr.hosts {
+		ret = append(ret, *hq)
+	}
+	sort.Slice(ret, func(i, k int) bool {
+		if ret[i].Priority != ret[k].Priority {
+			return ret[i].Priority > ret[k].Priority
+		}
+		return ret[i].Host < ret[k].Host
+	})
+	return ret
+}
+
+// update the state of host persistently
+func (fr *DiskFrontier) update(host string, fn func(hq *HostQueue)) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	hq, ok := fr.hosts[host]
+	if !ok {
+		hq = &HostQueue{Host: host}
+	}
+	upd := *hq
+	fn(&upd)
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		return putHost(tx, &upd)
+	})
+	if err != nil {
+		return err
+	}
+	fr.hosts[host] = &upd
+	return nil
+}
+
+// Pause passes over host in Next() until Resume.
+func (fr *DiskFrontier) Pause(host string) error {
+	return fr.update(host, func(hq *HostQueue) { hq.Paused = true })
+}
+
+// Resume a paused host.
+func (fr *DiskFrontier) Resume(host string) error {
+	return fr.update(host, func(hq *HostQueue) { hq.Paused = false })
+}
+
+// SetPriority of host; default is zero.
+func (fr *DiskFrontier) SetPriority(host string, priority int) error {
+	return fr.update(host, func(hq *HostQueue) { hq.Priority = priority })
+}
+
+// Drop discards the queued URLs of host.
+// They remain seen, and are not queued again.
+func (fr *DiskFrontier) Drop(host string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket(queueBucket(host))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if hq, ok := fr.hosts[host]; ok {
+		hq.Len = 0
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Group fetches related jobs concurrently - errgroup style.
+// The first fatal failure cancels all others.
+// Use it for "fetch these five dependent resources or fail".
+type Group struct {
+	// Fatal decides whether a finished job fails the group.
+	// Default: any error or a non 2xx status.
+	Fatal func(j *Job) error
+
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	jobs   []*Job
+	err    error
+}
+
+// NewGroup derives the shared context from ctx.
+func NewGroup(ctx context.Context) *Group {
+	g := &Group{}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	return g
+}
+
+func defaultFatal(j *Job) error {
+	if j.Err != nil {
+		return j.Err
+	}
+	if j.Status < 200 || j.Status > 299 {
+		return j.StatusErr()
+	}
+	return nil
+}
+
+// Go starts fetching j.
+func (g *Group) Go(j *Job) {
+	g.mu.Lock()
+	g.jobs = append(g.jobs, j)
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		j.FetchContext(g.ctx)
+		fatal := g.Fatal
+		if fatal == nil {
+			fatal = defaultFatal
+		}
+		if err := fatal(j); err != nil {
+			g.mu.Lock()
+			if g.err == nil {
+				g.err = err
+				g.cancel()
+			}
+			g.mu.Unlock()
+		}
+	}()
+}
+
+// Wait returns all jobs - in order of Go() -
+// and the first fatal error.
+func (g *Group) Wait() ([]*Job, error) {
+	g.wg.Wait()
+	g.cancel()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.jobs, g.err
+}
@@ -0,0 +1,60 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var ErrHeaderLimit = errors.New("response header limit exceeded")
+
+// HeaderLimitError reports which limit was exceeded.
+// errors.Is(err, ErrHeaderLimit) holds.
+type HeaderLimitError struct {
+	Limit string // "bytes", "count" or "set-cookie"
+	Got   int    // -1 if unknown
+	Max   int64
+}
+
+func (e *HeaderLimitError) Error() string {
+	if e.Got < 0 {
+		return fmt.Sprintf("%v: %v above %v", ErrHeaderLimit, e.Limit, e.Max)
+	}
+	return fmt.Sprintf("%v: %v %v above %v", ErrHeaderLimit, e.Limit, e.Got, e.Max)
+}
+
+func (e *HeaderLimitError) Is(target error) bool {
+	return target == ErrHeaderLimit
+}
+
+// checkHeaderLimits guards against broken
+// or malicious origins flooding us with headers.
+func (f *Job) checkHeaderLimits(h http.Header) error {
+	if f.MaxSetCookies > 0 && len(h["Set-Cookie"]) > f.MaxSetCookies {
+		return &HeaderLimitError{Limit: "set-cookie", Got: len(h["Set-Cookie"]), Max: int64(f.MaxSetCookies)}
+	}
+	if f.MaxHeaderCount > 0 {
+		cnt := 0
+		for _, vals := range h {
+			cnt += len(vals)
+		}
+		if cnt > f.MaxHeaderCount {
+			return &HeaderLimitError{Limit: "count", Got: cnt, Max: int64(f.MaxHeaderCount)}
+		}
+	}
+	return nil
+}
+
+// wrapHeaderLimit converts the transport's untyped error
+// for MaxResponseHeaderBytes.
+func (f *Job) wrapHeaderLimit(err error) error {
+	if strings.Contains(err.Error(), "server response headers exceeded") {
+		max := f.MaxHeaderBytes
+		if max <= 0 {
+			max = 1 << 20
+		}
+		return fmt.Errorf("%w: %v", &HeaderLimitError{Limit: "bytes", Got: -1, Max: max}, err)
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package fetch
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// injectSecretHeaders resolves SecretHeaders
+// and sets them on the request.
+// Values are never written to Msg.
+func (f *Job) injectSecretHeaders() error {
+	if len(f.SecretHeaders) == 0 {
+		return nil
+	}
+	for hdr, name := range f.SecretHeaders {
+		val, err := f.secrets().Get(name)
+		if err != nil {
+			return fmt.Errorf("header %v: %v", hdr, err)
+		}
+		f.Req.Header.Set(hdr, val)
+		f.log(slog.LevelDebug, "header set from secret", "header", hdr, "secret", name)
+	}
+	return nil
+}
+
+// AddHeader adds a request header value.
+// Unlike setting headers on a prebuilt Req,
+// this keeps the URL normalization path.
+func (j *Job) AddHeader(key, value string) {
+	if j.Headers == nil {
+		j.Headers = http.Header{}
+	}
+	j.Headers.Add(key, value)
+}
+
+// setHeaders adds Headers to the request.
+// Values set on a prebuilt request take precedence.
+func (f *Job) setHeaders() {
+	for k, vals := range f.Headers {
+		if f.Req.Header.Get(k) != "" {
+			continue
+		}
+		for _, v := range vals {
+			f.Req.Header.Add(k, v)
+		}
+	}
+}
+
+// setAttemptHeader sets a request header for the current attempt only -
+// validators, ranges, challenge responses.
+// The next attempt starts with the previous value again.
+func (f *Job) setAttemptHeader(key, value string) {
+	key = http.CanonicalHeaderKey(key)
+	if f.attemptHeaders == nil {
+		f.attemptHeaders = map[string][]string{}
+	}
+	if _, ok := f.attemptHeaders[key]; !ok {
+		f.attemptHeaders[key] = append([]string(nil), f.Req.Header.Values(key)...)
+	}
+	f.Req.Header.Set(key, value)
+}
+
+// restoreAttemptHeaders undoes setAttemptHeader
+func (f *Job) restoreAttemptHeaders() {
+	for key, prev := range f.attemptHeaders {
+		if len(prev) == 0 {
+			f.Req.Header.Del(key)
+		} else {
+			f.Req.Header[key] = prev
+		}
+	}
+	f.attemptHeaders = nil
+}
@@ -0,0 +1,72 @@
+package fetch
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// headCheck issues a HEAD request and decides
+// whether the GET is worthwhile.
+// If the server does not support HEAD, we GET anyway.
+// A skipped GET fails with ErrBodyTooLarge or ErrSkipped,
+// lest the empty body be taken for the real one.
+func (f *Job) headCheck(client *http.Client) (skip bool) {
+
+	head := f.Req.Clone(f.Req.Context())
+	head.Method = "HEAD"
+	head.Body = nil
+	resp, err := client.Do(head)
+	if err != nil {
+		f.log(slog.LevelWarn, "HEAD failed", "err", err)
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		f.log(slog.LevelInfo, "HEAD status", "status", resp.StatusCode)
+		return false
+	}
+
+	reason := ""
+	max := f.maxBytesFor(resp.Header.Get("Content-Type"))
+	switch {
+	case max > 0 && resp.ContentLength > max:
+		reason = fmt.Sprintf("size %v exceeds %v", resp.ContentLength, max)
+		f.Err = fmt.Errorf("%w: HEAD announced %v bytes", ErrBodyTooLarge, resp.ContentLength)
+	case len(f.AcceptTypes) > 0 && !acceptedType(resp.Header.Get("Content-Type"), f.AcceptTypes):
+		reason = fmt.Sprintf("content type %q not accepted", resp.Header.Get("Content-Type"))
+	case !f.Mod.IsZero() && !lastModified(resp.Header).IsZero() && !lastModified(resp.Header).After(f.Mod):
+		reason = fmt.Sprintf("not modified since %v", f.Mod.Format(http.TimeFormat))
+	}
+	if reason == "" {
+		return false
+	}
+
+	f.Status = resp.StatusCode
+	f.RespHeader = resp.Header
+	f.Skipped = reason
+	if f.Err == nil {
+		f.Err = fmt.Errorf("%w: %v", ErrSkipped, reason)
+	}
+	f.event("head", "GET skipped: %v", reason)
+	f.log(slog.LevelInfo, "GET skipped", "reason", reason)
+	return true
+}
+
+func acceptedType(ct string, accept []string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	for _, a := range accept {
+		if a == mt {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// idleBody aborts reads, which wait longer than idle for the next bytes -
+// servers sending chunked bodies that never end, or trickling streams.
+// The clock runs only while a Read is pending;
+// a slow consumer of a streamed body does not count.
+type idleBody struct {
+	rc      io.ReadCloser
+	idle    time.Duration
+	stalled atomic.Bool
+	timer   *time.Timer // armed during Read
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.idle, func() {
+			b.stalled.Store(true)
+			b.rc.Close() // unblocks the pending Read
+		})
+	} else {
+		b.timer.Reset(b.idle)
+	}
+	n, err := b.rc.Read(p)
+	b.timer.Stop()
+	if b.stalled.Load() {
+		return n, fmt.Errorf("%w: no bytes for %v", ErrBodyStalled, b.idle)
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	return b.rc.Close()
+}
+
+// watchIdle wraps the body with IdleTimeout
+func (f *Job) watchIdle(resp *http.Response) {
+	if f.IdleTimeout <= 0 {
+		return
+	}
+	resp.Body = &idleBody{rc: resp.Body, idle: f.IdleTimeout}
+	f.log(slog.LevelDebug, "idle body timeout", "idle", f.IdleTimeout)
+}
@@ -0,0 +1,62 @@
+package fetch
+
+import (
+	"net/url"
+)
+
+// targetHost is the host a job is going to request
+func targetHost(j *Job) string {
+	if j.Req != nil && j.Req.URL != nil {
+		return j.Req.URL.Hostname()
+	}
+	u, err := url.Parse(j.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// reserveHost takes a MaxInFlightPerHost slot for j.
+// If the host is saturated, j is parked,
+// to be picked up by the worker releasing the next slot.
+// Thus workers never idle while waiting for a busy host.
+func (b *Batch) reserveHost(j *Job) bool {
+	if b.MaxInFlightPerHost < 1 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hostBusy == nil {
+		b.hostBusy, b.hostParked = map[string]int{}, map[string][]*Job{}
+	}
+	host := targetHost(j)
+	if b.hostBusy[host] < b.MaxInFlightPerHost {
+		b.hostBusy[host]++
+		j.slotHost = host
+		return true
+	}
+	b.hostParked[host] = append(b.hostParked[host], j)
+	return false
+}
+
+// releaseHost hands the slot of finished job j
+// to the next parked job of the same host - if any.
+// The slot is that of the reserved host; rewrites, mirrors
+// or redirects may since have changed the target of j.
+func (b *Batch) releaseHost(j *Job) *Job {
+	if b.MaxInFlightPerHost < 1 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	host := j.slotHost
+	j.slotHost = ""
+	if parked := b.hostParked[host]; len(parked) > 0 {
+		next := parked[0]
+		b.hostParked[host] = parked[1:]
+		next.slotHost = host
+		return next
+	}
+	b.hostBusy[host]--
+	return nil
+}
@@ -0,0 +1,94 @@
+package fetch
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Jar is a cookie jar, which can be saved and restored.
+// The stdlib jar can not enumerate its cookies,
+// thus we keep a copy of every cookie set.
+type Jar struct {
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	entries map[string]jarEntry
+}
+
+type jarEntry struct {
+	URL    string
+	Cookie *http.Cookie
+}
+
+// NewJar uses the public suffix list,
+// so that no cookies are set for "co.uk".
+func NewJar() *Jar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &Jar{jar: jar, entries: map[string]jarEntry{}}
+}
+
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		cp := *c
+		if cp.MaxAge > 0 {
+			cp.Expires = time.Now().Add(time.Duration(cp.MaxAge) * time.Second)
+			cp.MaxAge = 0
+		}
+		domain := cp.Domain
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		key := domain + ";" + cp.Path + ";" + cp.Name
+		if c.MaxAge < 0 {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = jarEntry{URL: u.Scheme + "://" + u.Host + "/", Cookie: &cp}
+	}
+}
+
+// Save writes all unexpired cookies as JSON.
+func (j *Jar) Save(w io.Writer) error {
+	j.mu.Lock()
+	list := make([]jarEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if !e.Cookie.Expires.IsZero() && e.Cookie.Expires.Before(time.Now()) {
+			continue
+		}
+		list = append(list, e)
+	}
+	j.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+// Load restores cookies written by Save.
+func (j *Jar) Load(r io.Reader) error {
+	list := []jarEntry{}
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return err
+	}
+	for _, e := range list {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		j.SetCookies(u, []*http.Cookie{e.Cookie})
+	}
+	return nil
+}
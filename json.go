@@ -0,0 +1,48 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNotJSON is returned by DecodeJSON for other content types.
+var ErrNotJSON = errors.New("response is not json")
+
+// JSON fetches url and unmarshals the body into T.
+func JSON[T any](ctx context.Context, url string, opts ...Option) (T, error) {
+	var t T
+	j := New(url, opts...)
+	j.FetchContext(ctx)
+	if j.Err != nil {
+		return t, j.Err
+	}
+	err := j.DecodeJSON(&t)
+	return t, err
+}
+
+// DecodeJSON unmarshals the body of a fetched job into v.
+// The status must be 2xx and the content type json -
+// declared as application/json or */*+json, or sniffed.
+// Streamed bodies are decoded from Body().
+func (j *Job) DecodeJSON(v any) error {
+	if j.Err != nil {
+		return j.Err
+	}
+	if j.Status < 200 || j.Status > 299 {
+		return j.StatusErr()
+	}
+	ct := j.ContentType()
+	if ct != "application/json" && !strings.HasSuffix(ct, "+json") && j.SniffedType != "application/json" {
+		return fmt.Errorf("%w: %q for %v", ErrNotJSON, ct, j.URL)
+	}
+	if j.stream != nil {
+		body := j.Body()
+		defer body.Close()
+		return json.NewDecoder(body).Decode(v)
+	}
+	return json.Unmarshal(j.bts, v)
+}
@@ -0,0 +1,79 @@
+package fetch
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrorKind buckets the outcome of a Job; see Job.Kind.
+type ErrorKind string
+
+const (
+	KindNone       ErrorKind = ""                 // success - or a status below 400, or ErrSkipped
+	KindDNS        ErrorKind = "dns"              // lookup failed
+	KindConnect    ErrorKind = "connect"          // refused, unreachable
+	KindTLS        ErrorKind = "tls"              // handshake or certificate
+	KindTimeout    ErrorKind = "timeout"          // no response in time
+	KindRedirect   ErrorKind = "redirect-refused" // RedirectPolicy or too many hops
+	KindBodyRead   ErrorKind = "body-read"        // response arrived, the body failed or exceeded limits
+	KindHTTPStatus ErrorKind = "http-status"      // status 400 and above
+	KindCircuit    ErrorKind = "circuit-open"     // the Breaker failed fast
+	KindOther      ErrorKind = "other"
+)
+
+// Classify buckets the outcome of the last attempt.
+// Fetch() stores the result in Kind.
+func (j *Job) Classify() ErrorKind {
+	return kindOf(j.Err, j.Status)
+}
+
+func kindOf(err error, status int) ErrorKind {
+	if err == nil {
+		if status >= 400 {
+			return KindHTTPStatus
+		}
+		return KindNone
+	}
+	switch {
+	case errors.Is(err, ErrSkipped):
+		return KindNone
+	case errors.Is(err, ErrCircuitOpen):
+		return KindCircuit
+	case errors.Is(err, ErrRedirectBlocked), errors.Is(err, ErrTooManyRedirects):
+		return KindRedirect
+	case errors.Is(err, ErrBodyRead), errors.Is(err, ErrBodyTooLarge):
+		return KindBodyRead
+	case errors.Is(err, ErrBadStatus{}):
+		return KindHTTPStatus
+	case errors.Is(err, ErrDNS):
+		return KindDNS
+	case errors.Is(err, ErrTLS):
+		return KindTLS
+	case isDialError(err):
+		return KindConnect
+	case errors.Is(err, ErrTimeout):
+		return KindTimeout
+	}
+	return KindOther
+}
+
+// Permanent suggests the URL is dead - retrying later is pointless.
+// Non-existent domains, broken certificates, refused redirects
+// and client errors other than 408 and 429 count as permanent.
+func (j *Job) Permanent() bool {
+	switch j.Classify() {
+	case KindDNS:
+		var dnsErr *net.DNSError
+		return errors.As(j.Err, &dnsErr) && dnsErr.IsNotFound
+	case KindTLS, KindRedirect:
+		return true
+	case KindHTTPStatus:
+		code := j.Status
+		var bs ErrBadStatus
+		if errors.As(j.Err, &bs) {
+			code = bs.Code
+		}
+		return code >= 400 && code < 500 && code != 408 && code != 429
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package fetch
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// TextReport describes the natural language and character encoding
+// of a text response, for building multilingual corpora.
+type TextReport struct {
+	Charset            string  // as determined from BOM, header or meta tags, or guessed
+	CharsetConfidence  float64 // 1 for declared charsets
+	Language           string  // ISO 639-1; empty if undetermined
+	LanguageConfidence float64 // share of the best profile in all profile hits
+}
+
+// The most frequent trigrams, most frequent first.
+// Enough to tell the major european languages apart.
+var trigramProfiles = map[string][]string{
+	"en": {" th", "the", "he ", "and", " an", "nd ", " of", "of ", " to", "ing", "ng ", " in", "to ", "ed ", "is ", " a ", "ion", "er ", "in ", "tio", "es ", "re ", "ent", " is", "on ", "at ", "for", " fo", "or ", "hat"},
+	"de": {"en ", "er ", " de", "der", "ie ", "ich", "ein", "die", " di", "sch", "che", " un", "und", "nd ", "ch ", "cht", " ei", "in ", "den", "ine", "ung", "ng ", "te ", "gen", " da", "das", "es ", "nde", " ge", "ten"},
+	"fr": {"es ", " de", "de ", "ent", "le ", " le", "nt ", "la ", " la", "ion", "e d", "on ", "re ", "les", " pa", "que", "ue ", " et", "et ", "e l", " co", "tio", "ons", "des", "our", " qu", "men", "e p", "ait", " un"},
+	"es": {" de", "de ", "os ", "la ", " la", "el ", "es ", " el", "ión", " co", "ent", "en ", " en", "que", "ue ", "as ", "on ", "aci", "ado", "cio", " qu", "del", "los", " lo", "e l", "nte", "a d", "ra ", "sta", "o d"},
+	"it": {" di", "di ", "to ", "la ", " la", "re ", "che", "ent", "one", " de", "del", "ion", "zio", "e d", "no ", "ell", "lla", " co", "ne ", "per", " pe", "ato", "o d", "nte", "i d", "ti ", "ta ", " in", "a d", "ere"},
+	"nl": {"en ", "de ", " de", "an ", "van", " va", "et ", "het", "een", " he", " ee", "er ", "n d", "ing", "ijk", "and", "ver", " ve", "te ", "in ", "aar", "oor", "ten", "den", "nde", " in", "sch", "ng ", "erd", " ge"},
+	"pt": {" de", "de ", "os ", "ão ", "ção", "do ", " co", "ent", "es ", " qu", "que", "ue ", "as ", "da ", " da", "ra ", " pa", "nte", "a d", "o d", "em ", " em", "ado", "or ", "men", "açã", "par", " e ", "com", "con"},
+}
+
+// isText - we report only on human readable responses
+func isText(ct string) bool {
+	return strings.HasPrefix(ct, "text/") || strings.HasSuffix(ct, "xml") || ct == "application/xhtml+xml"
+}
+
+var (
+	stripScripts = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	stripTags    = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// reportText fills Text for text responses
+func (f *Job) reportText() {
+	ct := f.ContentType()
+	if !isText(ct) || len(f.bts) == 0 {
+		return
+	}
+	r := &TextReport{}
+	enc, name, certain := charset.DetermineEncoding(f.bts, f.RespHeader.Get("Content-Type"))
+	r.Charset = name
+	switch {
+	case certain:
+		r.CharsetConfidence = 1
+	case name == "utf-8" && utf8.Valid(f.bts):
+		r.CharsetConfidence = 0.8
+	default:
+		r.CharsetConfidence = 0.4
+	}
+
+	sample := f.bts
+	if len(sample) > 256<<10 {
+		sample = sample[:256<<10]
+	}
+	utf, err := enc.NewDecoder().Bytes(sample)
+	if err != nil {
+		utf = sample
+	}
+	txt := string(utf)
+	if strings.Contains(ct, "html") || strings.Contains(ct, "xml") {
+		txt = stripScripts.ReplaceAllString(txt, " ")
+		txt = stripTags.ReplaceAllString(txt, " ")
+	}
+	r.Language, r.LanguageConfidence = detectLanguage(txt)
+	f.Text = r
+	f.event("text", "charset %v (%.2f), language %q (%.2f)",
+		r.Charset, r.CharsetConfidence, r.Language, r.LanguageConfidence)
+}
+
+// detectLanguage scores the trigrams of txt against the profiles;
+// a hit on a frequent trigram counts more.
+func detectLanguage(txt string) (string, float64) {
+
+	// lower case letters, everything else collapses to single blanks
+	b := strings.Builder{}
+	blank := true
+	for _, r := range txt {
+		if unicode.IsLetter(r) {
+			b.WriteRune(unicode.ToLower(r))
+			blank = false
+		} else if !blank {
+			b.WriteByte(' ')
+			blank = true
+		}
+	}
+	runes := []rune(" " + b.String())
+	if len(runes) < 50 {
+		return "", 0
+	}
+
+	counts := map[string]int{}
+	for i := 0; i+3 <= len(runes); i++ {
+		counts[string(runes[i:i+3])]++
+	}
+
+	best, total, bestScore := "", 0, 0
+	for lang, profile := range trigramProfiles {
+		score := 0
+		for rank, tri := range profile {
+			score += counts[tri] * (len(profile) - rank)
+		}
+		total += score
+		if score > bestScore || score == bestScore && lang < best {
+			best, bestScore = lang, score
+		}
+	}
+	if bestScore == 0 {
+		return "", 0
+	}
+	return best, float64(bestScore) / float64(total)
+}
@@ -0,0 +1,129 @@
+package fetch
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+// Limiter enforces requests per second and burst per host.
+// Share one instance across jobs and batches.
+// Fetches block until their turn - or until their context is done.
+type Limiter struct {
+	RPS   float64 // default for hosts without SetHost
+	Burst int
+
+	mu     sync.Mutex
+	rates  map[string]rate.Limit // SetHost overrides
+	burst  map[string]int
+	hosts  map[string]*rate.Limiter
+	paused map[string]time.Time
+}
+
+// NewLimiter with a default rate for all hosts.
+// Burst below 1 is raised to 1.
+func NewLimiter(rps float64, burst int) *Limiter {
+	return &Limiter{RPS: rps, Burst: burst}
+}
+
+// SetDefault changes the rate for all hosts without SetHost.
+func (l *Limiter) SetDefault(rps float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.RPS, l.Burst = rps, burst
+	r, b := rate.Limit(rps), burst
+	if r <= 0 {
+		r = rate.Inf
+	}
+	if b < 1 {
+		b = 1
+	}
+	for host, lim := range l.hosts {
+		if _, ok := l.rates[host]; !ok {
+			lim.SetLimit(r)
+			lim.SetBurst(b)
+		}
+	}
+}
+
+// SetHost overrides the rate for one host.
+func (l *Limiter) SetHost(host string, rps float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rates == nil {
+		l.rates, l.burst = map[string]rate.Limit{}, map[string]int{}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	l.rates[host], l.burst[host] = rate.Limit(rps), burst
+	if lim, ok := l.hosts[host]; ok {
+		lim.SetLimit(rate.Limit(rps))
+		lim.SetBurst(burst)
+	}
+}
+
+func (l *Limiter) host(host string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lim, ok := l.hosts[host]; ok {
+		return lim
+	}
+	r, b := rate.Limit(l.RPS), l.Burst
+	if rr, ok := l.rates[host]; ok {
+		r, b = rr, l.burst[host]
+	}
+	if r <= 0 {
+		r = rate.Inf
+	}
+	if b < 1 {
+		b = 1
+	}
+	if l.hosts == nil {
+		l.hosts = map[string]*rate.Limiter{}
+	}
+	lim := rate.NewLimiter(r, b)
+	l.hosts[host] = lim
+	return lim
+}
+
+// Pause blocks all requests to host until the given time,
+// i.e. after the upstream throttled us.
+func (l *Limiter) Pause(host string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.paused == nil {
+		l.paused = map[string]time.Time{}
+	}
+	if until.After(l.paused[host]) {
+		l.paused[host] = until
+	}
+}
+
+// Wait blocks until host may be requested.
+func (l *Limiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	until := l.paused[host]
+	l.mu.Unlock()
+	if d := time.Until(until); d > 0 && !sleepContext(ctx, d) {
+		return ctx.Err()
+	}
+	return l.host(host).Wait(ctx)
+}
+
+func (f *Job) waitLimiter() error {
+	if f.Limiter == nil {
+		return nil
+	}
+	start := time.Now()
+	if err := f.Limiter.Wait(f.Req.Context(), f.Req.URL.Hostname()); err != nil {
+		return fmt.Errorf("rate limit: %w", err)
+	}
+	if waited := time.Since(start); waited > 10*time.Millisecond {
+		f.event("ratelimit", "waited %v for %v", waited.Round(time.Millisecond), f.Req.URL.Hostname())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package fetch
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Logger receives the log entries of a fetch - with levels and key/value pairs.
+// *slog.Logger implements it.
+//
+// Without Logger, entries are aggregated into Msg, as they always were;
+// debug entries only with LogLevel > 0.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// WithLogger sends log entries to l instead of Msg.
+func WithLogger(l Logger) Option {
+	return func(j *Job) {
+		j.Logger = l
+	}
+}
+
+// logValue redacts urls in values, which might carry them
+func (f *Job) logValue(v any) any {
+	switch vv := v.(type) {
+	case string:
+		return f.redact(vv)
+	case *url.URL:
+		return f.redactURL(vv.String())
+	case error:
+		return f.redact(vv.Error())
+	case fmt.Stringer:
+		return f.redact(vv.String())
+	}
+	return v
+}
+
+func (f *Job) log(level slog.Level, msg string, args ...any) {
+	for i := 1; i < len(args); i += 2 {
+		args[i] = f.logValue(args[i])
+	}
+
+	if f.Logger != nil {
+		ctx := context.Background()
+		if f.Req != nil {
+			ctx = f.Req.Context()
+		}
+		f.Logger.Log(ctx, level, msg, append([]any{"url", f.redactURL(f.URL), "trace_id", f.TraceID}, args...)...)
+		return
+	}
+
+	if level < slog.LevelInfo && f.LogLevel <= 0 {
+		return
+	}
+	b := &strings.Builder{}
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(b, " %v=%v", args[i], args[i+1])
+	}
+	b.WriteString("\n")
+	f.Msg += b.String()
+}
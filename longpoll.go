@@ -0,0 +1,124 @@
+package fetch
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// LongPoll repeatedly requests URL, the server holding
+// each request until it has news or Wait has passed.
+// Responses with a body are delivered;
+// timeouts and empty responses (204, 304, 408, 504 or no body)
+// are normal and lead to the next poll immediately -
+// unless they came back before Wait had passed;
+// then polls back off, lest a server ignoring Wait is hammered.
+// Other failures reconnect with the backoff of the job options.
+type LongPoll struct {
+	URL       string
+	Wait      time.Duration // server side wait, default 30 seconds
+	WaitParam string        // query parameter telling the server the wait in seconds, i.e. "timeout"; empty to omit
+	Opts      []Option      // applied to every poll
+
+	// Next optionally adapts the following request to the last delivered one,
+	// i.e. by setting a "since" cursor into the query.
+	Next func(last *Job, u *url.URL)
+
+	// OnError optionally observes failed polls before reconnecting.
+	OnError func(j *Job, reconnectIn time.Duration)
+}
+
+func (lp *LongPoll) wait() time.Duration {
+	if lp.Wait <= 0 {
+		return 30 * time.Second
+	}
+	return lp.Wait
+}
+
+// isTimeout - the server did not answer within Wait
+func isTimeout(err error) bool {
+	return errors.Is(classify(err), ErrTimeout)
+}
+
+func emptyPoll(j *Job) bool {
+	switch j.Status {
+	case 204, 304, 408, 504:
+		return true
+	}
+	return len(j.bts) == 0 && j.SpillPath == ""
+}
+
+// Run polls until ctx is done and then closes the channel.
+// The channel must be drained.
+func (lp *LongPoll) Run(ctx context.Context) <-chan *Job {
+	out := make(chan *Job)
+	go func() {
+		defer close(out)
+		var last *Job
+		failures, quick := 0, 0
+		for ctx.Err() == nil {
+			u, err := url.Parse(lp.URL)
+			if err != nil {
+				j := New(lp.URL, lp.Opts...)
+				j.Err = err
+				select {
+				case out <- j:
+				case <-ctx.Done():
+				}
+				return
+			}
+			if lp.WaitParam != "" {
+				q := u.Query()
+				q.Set(lp.WaitParam, strconv.Itoa(int(lp.wait()/time.Second)))
+				u.RawQuery = q.Encode()
+			}
+			if lp.Next != nil && last != nil {
+				lp.Next(last, u)
+			}
+
+			j := New(u.String(), lp.Opts...)
+			j.Timeout = (lp.wait() + 10*time.Second + time.Second - 1) / time.Second // outlast the server
+			j.MaxAttempts = 1                                                        // we retry ourselves
+			start := time.Now()
+			j.FetchContext(ctx)
+
+			switch {
+			case ctx.Err() != nil:
+				return
+			case j.Err != nil && isTimeout(j.Err), j.Err == nil && emptyPoll(j):
+				failures = 0
+				if time.Since(start) >= lp.wait() {
+					quick = 0
+					continue
+				}
+				quick++
+				if !sleepContext(ctx, j.backoff(quick)) {
+					return
+				}
+				continue
+			case j.Err != nil || j.Status > 299:
+				failures++
+				wait := j.backoff(failures)
+				if lp.OnError != nil {
+					lp.OnError(j, wait)
+				}
+				if !sleepContext(ctx, wait) {
+					return
+				}
+				continue
+			}
+
+			failures, quick = 0, 0
+			last = j
+			select {
+			case out <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
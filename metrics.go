@@ -0,0 +1,130 @@
+package fetch
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics exports fetch counters, histograms and gauges to Prometheus.
+type Metrics struct {
+	requests *prometheus.CounterVec   // host, status, outcome
+	latency  *prometheus.HistogramVec // host
+	size     *prometheus.HistogramVec // host
+	inFlight prometheus.Gauge
+}
+
+// register c - or reuse the identical collector
+// registered by a previous call for the same registry.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// NewMetrics registers the fetch metrics with reg.
+// Calling it again for the same registry returns
+// metrics sharing the registered collectors.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{}
+	var err error
+	m.requests, err = register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fetch_requests_total",
+		Help: "Fetches by host, status and outcome.",
+	}, []string{"host", "status", "outcome"}))
+	if err != nil {
+		return nil, err
+	}
+	m.latency, err = register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "fetch_duration_seconds",
+		Help:    "Fetch duration including retries and body download.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2.5, 10),
+	}, []string{"host"}))
+	if err != nil {
+		return nil, err
+	}
+	m.size, err = register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "fetch_body_bytes",
+		Help:    "Decoded response body size.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"host"}))
+	if err != nil {
+		return nil, err
+	}
+	m.inFlight, err = register(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fetch_in_flight",
+		Help: "Fetches currently running.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WithMetrics records the job into reg; see NewMetrics().
+// Registration errors other than re-registration panic,
+// like prometheus.MustRegister.
+func WithMetrics(reg prometheus.Registerer) Option {
+	m, err := NewMetrics(reg)
+	if err != nil {
+		panic(err)
+	}
+	return func(j *Job) {
+		j.Metrics = m
+	}
+}
+
+// outcome classifies a finished job
+func outcome(j *Job) string {
+	switch {
+	case j.Skipped != "":
+		return "skipped"
+	case j.Err != nil:
+		return "error"
+	case j.Status >= 400:
+		return "http_error"
+	}
+	return "success"
+}
+
+func (m *Metrics) start() {
+	if m != nil {
+		m.inFlight.Inc()
+	}
+}
+
+func (m *Metrics) record(j *Job) {
+	if m == nil {
+		return
+	}
+	m.inFlight.Dec()
+	host := jobHost(j)
+	ex := prometheus.Labels{"trace_id": j.TraceID}
+	c := m.requests.WithLabelValues(host, strconv.Itoa(j.Status), outcome(j))
+	if ea, ok := c.(prometheus.ExemplarAdder); ok && j.TraceID != "" {
+		ea.AddWithExemplar(1, ex)
+	} else {
+		c.Inc()
+	}
+	observe(m.latency.WithLabelValues(host), j.Elapsed.Seconds(), ex)
+	if j.Err == nil {
+		observe(m.size.WithLabelValues(host), float64(j.BytesDecoded), ex)
+	}
+}
+
+// observe with the trace as exemplar, for jumping from a histogram bucket to the trace
+func observe(o prometheus.Observer, v float64, ex prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && ex["trace_id"] != "" {
+		eo.ObserveWithExemplar(v, ex)
+		return
+	}
+	o.Observe(v)
+}
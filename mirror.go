@@ -0,0 +1,68 @@
+package fetch
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/zew/util"
+	"golang.org/x/net/context"
+)
+
+// mirrorFailed - worth trying the next mirror
+func (f *Job) mirrorFailed() bool {
+	if f.local() || f.Skipped != "" {
+		return false
+	}
+	if f.Err != nil {
+		return !errors.Is(f.Err, context.Canceled) && !errors.Is(f.Err, context.DeadlineExceeded)
+	}
+	return f.Status >= 400
+}
+
+// nextMirror switches the request to the next of Mirrors,
+// after the current url failed for all its attempts.
+func (f *Job) nextMirror(ctx context.Context) bool {
+	for f.mirror < len(f.Mirrors) && f.Req != nil && ctx.Err() == nil && f.mirrorFailed() {
+		next := f.Mirrors[f.mirror]
+		f.mirror++
+		u, err := util.UrlParseImproved(next)
+		if err != nil {
+			f.log(slog.LevelWarn, "invalid mirror", "mirror", next, "err", err)
+			continue
+		}
+		if !f.rewindBody() {
+			f.log(slog.LevelWarn, "cannot try mirror: request body is not replayable")
+			return false
+		}
+		if f.primary == nil {
+			f.primary = f.Req.URL
+		}
+		f.event("mirror", "%v failed (%v); trying %v", f.Req.URL, f.Classify(), u)
+		f.log(slog.LevelWarn, "trying mirror", "failed", f.Req.URL, "reason", f.Classify(), "mirror", u)
+		f.Req.URL = u
+		f.Req.Host = ""
+		f.attemptBase = f.Attempts
+		f.rewritten = false
+		f.resetResponse()
+		return true
+	}
+	return false
+}
+
+// resetMirrors restores the primary url for another Fetch()
+func (f *Job) resetMirrors() {
+	if f.primary != nil && f.Req != nil {
+		f.Req.URL = f.primary
+	}
+	f.primary, f.mirror, f.attemptBase = nil, 0, 0
+	f.rewritten = false
+	f.MirrorURL = ""
+}
+
+// recordMirror notes the url which answered
+func (f *Job) recordMirror() {
+	if len(f.Mirrors) == 0 || f.Req == nil || f.mirrorFailed() {
+		return
+	}
+	f.MirrorURL = f.Req.URL.String()
+}
@@ -0,0 +1,190 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/zew/util"
+)
+
+// MultipartBatch combines the requests of subs into one
+// multipart/mixed request to the batch endpoint j.URL,
+// as used by OData $batch and Google batch endpoints.
+// Inner responses are mapped back onto subs -
+// by Content-ID, or by order, if the server omits them.
+// Subs without prebuilt Req are GET requests for their URL.
+func MultipartBatch(j *Job, subs []*Job) error {
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for i, sub := range subs {
+		if sub.Req == nil {
+			u, err := util.UrlParseImproved(sub.URL)
+			if err != nil {
+				return fmt.Errorf("sub job %v: %v", i, err)
+			}
+			sub.Req, err = http.NewRequest("GET", u.String(), nil)
+			if err != nil {
+				return fmt.Errorf("sub job %v: %v", i, err)
+			}
+		}
+		hdr := textproto.MIMEHeader{}
+		hdr.Set("Content-Type", "application/http")
+		hdr.Set("Content-Transfer-Encoding", "binary")
+		hdr.Set("Content-ID", fmt.Sprintf("<%d>", i+1))
+		pw, err := mw.CreatePart(hdr)
+		if err != nil {
+			return err
+		}
+		if err := writeInnerRequest(pw, sub); err != nil {
+			return fmt.Errorf("sub job %v: %v", i, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	u, err := util.UrlParseImproved(j.URL)
+	if err != nil {
+		return err
+	}
+	j.Req, err = http.NewRequest("POST", u.String(), bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	j.Req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+
+	j.Fetch()
+	if j.Err != nil {
+		return j.Err
+	}
+	if j.Status < 200 || j.Status > 299 {
+		return fmt.Errorf("batch: %w", j.StatusErr())
+	}
+
+	parts, err := parseMultipartResponses(j.RespHeader.Get("Content-Type"), j.bts)
+	if err != nil {
+		return err
+	}
+	for i, p := range parts {
+		idx := i
+		if id, ok := contentIDIndex(p.id); ok && id < len(subs) {
+			idx = id
+		}
+		if idx >= len(subs) {
+			continue
+		}
+		sub := subs[idx]
+		sub.Status = p.resp.StatusCode
+		sub.RespHeader = p.resp.Header
+		sub.Mod = lastModified(p.resp.Header)
+		sub.bts = p.body
+		sub.Err = p.err
+	}
+	if len(parts) != len(subs) {
+		return fmt.Errorf("batch returned %v responses for %v requests", len(parts), len(subs))
+	}
+	return nil
+}
+
+func writeInnerRequest(w io.Writer, sub *Job) error {
+	req := sub.Req
+	fmt.Fprintf(w, "%v %v HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	fmt.Fprintf(w, "Host: %v\r\n", req.URL.Host)
+	for k, vals := range sub.Headers {
+		if req.Header.Get(k) == "" {
+			for _, v := range vals {
+				fmt.Fprintf(w, "%v: %v\r\n", k, v)
+			}
+		}
+	}
+	if err := req.Header.Write(w); err != nil {
+		return err
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		_, err := io.WriteString(w, "\r\n")
+		return err
+	}
+	bts, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(bts))
+	_, err = w.Write(bts)
+	return err
+}
+
+type innerResponse struct {
+	id   string
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// parseMultipartResponses descends into nested
+// multipart/mixed parts - OData change sets.
+func parseMultipartResponses(contentType string, bts []byte) ([]innerResponse, error) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("batch response content type: %v", err)
+	}
+	if !strings.HasPrefix(mt, "multipart/") {
+		return nil, fmt.Errorf("batch response is %v, not multipart", mt)
+	}
+	ret := []innerResponse{}
+	mr := multipart.NewReader(bytes.NewReader(bts), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return ret, err
+		}
+		pbts, err := ioutil.ReadAll(part)
+		if err != nil {
+			return ret, err
+		}
+		pct := part.Header.Get("Content-Type")
+		if strings.HasPrefix(pct, "multipart/") {
+			nested, err := parseMultipartResponses(pct, pbts)
+			ret = append(ret, nested...)
+			if err != nil {
+				return ret, err
+			}
+			continue
+		}
+		ir := innerResponse{id: part.Header.Get("Content-ID")}
+		ir.resp, ir.err = http.ReadResponse(bufio.NewReader(bytes.NewReader(pbts)), nil)
+		if ir.err == nil {
+			ir.body, ir.err = ioutil.ReadAll(ir.resp.Body)
+			ir.resp.Body.Close()
+		} else {
+			ir.resp = &http.Response{Header: http.Header{}}
+		}
+		ret = append(ret, ir)
+	}
+}
+
+// contentIDIndex maps "<3>", "<response-3>" or "response-3"
+// back to the zero based sub job index
+func contentIDIndex(id string) (int, bool) {
+	id = strings.Trim(id, "<>")
+	if pos := strings.LastIndexAny(id, "-+"); pos > -1 {
+		id = id[pos+1:]
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n - 1, true
+}
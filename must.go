@@ -0,0 +1,24 @@
+package fetch
+
+// MustFetch collapses the fetch - check status - read body dance.
+// Despite its name, it does not panic.
+// The body is only returned for 2xx responses within maxBytes.
+// maxBytes <= 0 means no limit.
+func (j *Job) MustFetch(maxBytes int64) ([]byte, error) {
+	j.callMaxBytes = maxBytes // for this call; MaxBytes stays as configured
+	defer func() { j.callMaxBytes = 0 }()
+	j.Fetch()
+	if j.Err != nil {
+		return nil, j.Err
+	}
+	if j.Status < 200 || j.Status > 299 {
+		return nil, j.StatusErr()
+	}
+	return j.bts, nil
+}
+
+// MustFetch is the string url shortcut of Job.MustFetch()
+func MustFetch(url string, maxBytes int64) ([]byte, error) {
+	j := Job{URL: url}
+	return j.MustFetch(maxBytes)
+}
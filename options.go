@@ -0,0 +1,128 @@
+package fetch
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a Job; see New().
+type Option func(*Job)
+
+// New creates a job for url.
+// Options are applied in order.
+func New(url string, opts ...Option) *Job {
+	j := &Job{URL: url}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// WithTimeout sets the total timeout.
+// Job.Timeout counts seconds, thus d is rounded up to full seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(j *Job) {
+		j.Timeout = (d + time.Second - 1) / time.Second
+	}
+}
+
+// WithHeaders adds request headers.
+func WithHeaders(h http.Header) Option {
+	return func(j *Job) {
+		if j.Headers == nil {
+			j.Headers = http.Header{}
+		}
+		for k, vals := range h {
+			for _, v := range vals {
+				j.Headers.Add(k, v)
+			}
+		}
+	}
+}
+
+// WithHeader adds a single request header.
+func WithHeader(key, value string) Option {
+	return func(j *Job) {
+		j.AddHeader(key, value)
+	}
+}
+
+// WithRedirectPolicy sets the redirect policy.
+func WithRedirectPolicy(p RedirectPolicy) Option {
+	return func(j *Job) {
+		j.Redirect = p
+	}
+}
+
+// WithLogLevel sets the verbosity of Msg; above 0, debug entries are included.
+func WithLogLevel(level int) Option {
+	return func(j *Job) {
+		j.LogLevel = level
+	}
+}
+
+// WithMaxBytes limits the body size.
+func WithMaxBytes(n int64) Option {
+	return func(j *Job) {
+		j.MaxBytes = n
+	}
+}
+
+// WithSecretHeaders sets headers, whose values are
+// looked up from secrets on every fetch.
+func WithSecretHeaders(secrets Secrets, headers map[string]string) Option {
+	return func(j *Job) {
+		j.Secrets = secrets
+		j.SecretHeaders = headers
+	}
+}
+
+// WithAppengine provides the incoming request for an appengine context.
+func WithAppengine(aeReq *http.Request) Option {
+	return func(j *Job) {
+		j.AeReq = aeReq
+	}
+}
+
+// WithHostHeader see Job.HostHeader.
+func WithHostHeader(host string) Option {
+	return func(j *Job) {
+		j.HostHeader = host
+	}
+}
+
+// WithLocalAddr see Job.LocalAddr.
+func WithLocalAddr(addr string) Option {
+	return func(j *Job) {
+		j.LocalAddr = addr
+	}
+}
+
+// WithJar shares a cookie jar.
+func WithJar(jar http.CookieJar) Option {
+	return func(j *Job) {
+		j.Jar = jar
+	}
+}
+
+// WithRetryStatus retries up to attempts times upon the given status codes.
+func WithRetryStatus(attempts int, codes ...int) Option {
+	return func(j *Job) {
+		j.MaxAttempts = attempts
+		j.RetryStatus = codes
+	}
+}
+
+// WithMirrors adds alternative urls; see Job.Mirrors.
+func WithMirrors(urls ...string) Option {
+	return func(j *Job) {
+		j.Mirrors = append(j.Mirrors, urls...)
+	}
+}
+
+// WithProxy see Job.Proxy.
+func WithProxy(proxyURL string) Option {
+	return func(j *Job) {
+		j.Proxy = proxyURL
+	}
+}
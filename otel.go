@@ -0,0 +1,88 @@
+package fetch
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// WithTracing emits a span per fetch and per attempt; see Job.Tracer.
+func WithTracing(tp trace.TracerProvider) Option {
+	return func(j *Job) {
+		j.Tracer = tp.Tracer("github.com/pbberlin/fetch")
+	}
+}
+
+// startSpan starts the span of the whole fetch.
+func (f *Job) startSpan(ctx context.Context) (context.Context, func()) {
+	if f.Tracer == nil {
+		return ctx, func() {}
+	}
+	ctx, span := f.Tracer.Start(ctx, "fetch", trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, func() {
+		span.SetAttributes(
+			attribute.String("url.full", f.redactURL(f.URL)),
+			attribute.Int("fetch.attempts", f.Attempts),
+		)
+		f.endSpan(span)
+	}
+}
+
+// startAttemptSpan starts the client span of a single attempt,
+// with the semantic HTTP attributes.
+func (f *Job) startAttemptSpan(ctx context.Context) (context.Context, func()) {
+	if f.Tracer == nil {
+		return ctx, func() {}
+	}
+	method := "GET"
+	if f.Req != nil {
+		method = f.Req.Method
+	}
+	ctx, span := f.Tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, func() {
+		attrs := []attribute.KeyValue{attribute.String("http.request.method", method)}
+		if f.Req != nil {
+			attrs = append(attrs,
+				attribute.String("url.full", f.redactURL(f.Req.URL.String())),
+				attribute.String("server.address", f.Req.URL.Hostname()),
+			)
+		}
+		if f.Attempts > 1 {
+			attrs = append(attrs, attribute.Int("http.request.resend_count", f.Attempts-1))
+		}
+		if f.Status > 0 {
+			attrs = append(attrs, attribute.Int("http.response.status_code", f.Status))
+		}
+		span.SetAttributes(attrs...)
+		f.endSpan(span)
+	}
+}
+
+func (f *Job) endSpan(span trace.Span) {
+	switch {
+	case f.Err != nil:
+		err := f.redactErr(f.Err) // attempt spans end before finish redacts
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case f.Status >= 500:
+		span.SetStatus(codes.Error, fmt.Sprintf("status %v", f.Status))
+	}
+	span.End()
+}
+
+// injectTraceparent propagates the span of ctx -
+// ours or one supplied by the caller - as W3C traceparent.
+// Without Tracer, a traceparent set by the caller is kept.
+func (f *Job) injectTraceparent(ctx context.Context) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	if f.Tracer == nil && f.Req.Header.Get("traceparent") != "" {
+		return
+	}
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(f.Req.Header))
+}
@@ -0,0 +1,53 @@
+package fetch
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// AcceptProto is the Accept header for protobuf endpoints;
+// JSON is accepted as the lesser alternative.
+const AcceptProto = "application/x-protobuf, application/protobuf;q=0.9, application/json;q=0.5"
+
+// WithAcceptProto asks for protobuf responses; see DecodeProto().
+func WithAcceptProto() Option {
+	return func(j *Job) {
+		if j.Headers.Get("Accept") == "" {
+			j.AddHeader("Accept", AcceptProto)
+		}
+	}
+}
+
+// DecodeProto unmarshals the body of a fetched job into m.
+// Binary protobuf is expected as application/x-protobuf,
+// application/protobuf or application/vnd.google.protobuf;
+// JSON responses are decoded via protojson.
+func (j *Job) DecodeProto(m proto.Message) error {
+	if j.Err != nil {
+		return j.Err
+	}
+	if j.Status < 200 || j.Status > 299 {
+		return j.StatusErr()
+	}
+	bts := j.bts
+	if j.stream != nil {
+		body := j.Body()
+		defer body.Close()
+		var err error
+		bts, err = ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+	}
+	switch ct := j.ContentType(); ct {
+	case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+		return proto.Unmarshal(bts, m)
+	case "application/json":
+		return protojson.Unmarshal(bts, m)
+	default:
+		return fmt.Errorf("%w: %q for %v; want protobuf", ErrNoCodec, ct, j.URL)
+	}
+}
@@ -0,0 +1,89 @@
+package fetch
+
+import (
+	"sync"
+	"time"
+)
+
+// QuotaUsage is a snapshot of QuotaTracker
+type QuotaUsage struct {
+	Day      string // quota day, Pacific time, i.e. "2016-04-23"
+	Requests int64
+	BytesOut int64 // request bodies
+	BytesIn  int64 // response bodies
+}
+
+// QuotaTracker counts urlfetch consumption of this instance.
+// Counters restart with each quota day - midnight Pacific time.
+// OnSoftLimit is called once per day, when a soft limit is crossed,
+// to stop runaway fetch loops before the quota is exhausted.
+type QuotaTracker struct {
+	SoftRequests int64 // 0 means no limit
+	SoftBytes    int64 // in plus out; 0 means no limit
+	OnSoftLimit  func(QuotaUsage)
+
+	mu    sync.Mutex
+	usage QuotaUsage
+	fired bool
+}
+
+// URLFetchQuota records every fetch via the appengine urlfetch client.
+var URLFetchQuota = &QuotaTracker{}
+
+var pacific = func() *time.Location {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.FixedZone("PST", -8*3600) // no tzdata on the instance
+	}
+	return loc
+}()
+
+func (q *QuotaTracker) record(out, in int64) {
+	q.mu.Lock()
+	day := time.Now().In(pacific).Format("2006-01-02")
+	if q.usage.Day != day {
+		q.usage = QuotaUsage{Day: day}
+		q.fired = false
+	}
+	q.usage.Requests++
+	q.usage.BytesOut += out
+	q.usage.BytesIn += in
+	exceeded := (q.SoftRequests > 0 && q.usage.Requests >= q.SoftRequests) ||
+		(q.SoftBytes > 0 && q.usage.BytesIn+q.usage.BytesOut >= q.SoftBytes)
+	fire := exceeded && !q.fired && q.OnSoftLimit != nil
+	if fire {
+		q.fired = true
+	}
+	usage := q.usage
+	q.mu.Unlock()
+
+	if fire {
+		q.OnSoftLimit(usage)
+	}
+}
+
+// Usage returns the counters of the current quota day.
+func (q *QuotaTracker) Usage() QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.usage
+}
+
+// Reset clears the counters.
+func (q *QuotaTracker) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.usage = QuotaUsage{}
+	q.fired = false
+}
+
+func (f *Job) recordQuota() {
+	if f.clientKind != "urlfetch" || f.DryRun || f.local() {
+		return
+	}
+	out := int64(0)
+	if f.Req != nil && f.Req.ContentLength > 0 {
+		out = f.Req.ContentLength
+	}
+	URLFetchQuota.record(out, int64(len(f.bts)))
+}
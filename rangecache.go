@@ -0,0 +1,260 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RangeCache keeps byte ranges of objects,
+// so that repeated partial fetches of the same object -
+// media style - do not hit the origin again.
+// Overlapping ranges are stitched together.
+// Partially cached ranges are fetched with If-Range,
+// thus a changed object replaces the cached ranges.
+// Only GET requests with a single Range header are considered.
+// Share one instance across jobs.
+type RangeCache struct {
+	MaxBytes int64 // least recently used objects are evicted beyond
+
+	mu      sync.Mutex
+	objects map[string]*rangeObject
+	size    int64
+}
+
+type rangeSpan struct {
+	start int64
+	data  []byte
+}
+
+type rangeObject struct {
+	validator string      // strong ETag or Last-Modified; never empty
+	total     int64       // -1 if unknown
+	header    http.Header // of the latest response
+	spans     []rangeSpan // sorted, neither overlapping nor adjacent
+	used      time.Time
+}
+
+func (o *rangeObject) size() int64 {
+	n := int64(0)
+	for _, s := range o.spans {
+		n += int64(len(s.data))
+	}
+	return n
+}
+
+// NewRangeCache with a default of 64 MB
+func NewRangeCache(maxBytes int64) *RangeCache {
+	if maxBytes < 1 {
+		maxBytes = 64 << 20
+	}
+	return &RangeCache{MaxBytes: maxBytes, objects: map[string]*rangeObject{}}
+}
+
+// parseRange reads "bytes=a-b", "bytes=a-" and "bytes=-n";
+// end is inclusive and -1 for open ranges, start is -n for suffixes.
+func parseRange(h string) (start, end int64, ok bool) {
+	if !strings.HasPrefix(h, "bytes=") || strings.Contains(h, ",") {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimSpace(h[6:]), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	if parts[0] == "" {
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		return -n, -1, err == nil && n > 0
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if parts[1] == "" {
+		return start, -1, true
+	}
+	end, err = strconv.ParseInt(parts[1], 10, 64)
+	return start, end, err == nil && end >= start
+}
+
+// parseContentRange reads "bytes a-b/total" and "bytes a-b/*"
+func parseContentRange(h string) (start, end, total int64, ok bool) {
+	total = -1
+	if _, err := fmt.Sscanf(h, "bytes %d-%d/%d", &start, &end, &total); err == nil {
+		return start, end, total, end >= start
+	}
+	if _, err := fmt.Sscanf(h, "bytes %d-%d/*", &start, &end); err == nil {
+		return start, end, -1, end >= start
+	}
+	return 0, 0, 0, false
+}
+
+func validator(h http.Header) string {
+	if et := h.Get("ETag"); et != "" && !strings.HasPrefix(et, "W/") {
+		return et
+	}
+	return h.Get("Last-Modified")
+}
+
+// absolute resolves open and suffix ranges against the total size
+func (o *rangeObject) absolute(start, end int64) (int64, int64, bool) {
+	if start < 0 || end < 0 {
+		if o.total < 0 {
+			return 0, 0, false
+		}
+		if start < 0 {
+			start = o.total + start
+			if start < 0 {
+				start = 0
+			}
+		}
+		end = o.total - 1
+	}
+	if o.total >= 0 && end >= o.total {
+		end = o.total - 1
+	}
+	return start, end, start <= end
+}
+
+// serve answers a cached range; otherwise it adds If-Range.
+// An If-Range of the caller must match the stored validator;
+// ranges of another version are neither served nor completed.
+func (c *RangeCache) serve(f *Job) bool {
+	if f.Req.Method != "GET" {
+		return false
+	}
+	start, end, ok := parseRange(f.Req.Header.Get("Range"))
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, found := c.objects[f.Req.URL.String()]
+	if !found {
+		return false
+	}
+	if ir := f.Req.Header.Get("If-Range"); ir != "" && ir != o.validator {
+		return false
+	}
+	f.setAttemptHeader("If-Range", o.validator)
+	start, end, ok = o.absolute(start, end)
+	if !ok {
+		return false
+	}
+	for _, s := range o.spans {
+		if s.start <= start && s.start+int64(len(s.data)) > end {
+			o.used = time.Now()
+			f.bts = append([]byte(nil), s.data[start-s.start:end-s.start+1]...)
+			f.Status = http.StatusPartialContent
+			f.RespHeader = o.header.Clone()
+			total := "*"
+			if o.total >= 0 {
+				total = strconv.FormatInt(o.total, 10)
+			}
+			f.RespHeader.Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", start, end, total))
+			f.RespHeader.Set("Content-Length", strconv.Itoa(len(f.bts)))
+			f.event("cache", "range %v-%v served from cache", start, end)
+			return true
+		}
+	}
+	return false
+}
+
+// store keeps the range of a 206 response,
+// or the full body of a 200 response to a range request.
+// Responses without strong ETag or Last-Modified are not kept,
+// since their ranges could not be told apart from those of another version.
+func (c *RangeCache) store(f *Job, resp *http.Response) {
+	if f.Req.Method != "GET" || f.decoding != "" || f.Req.Header.Get("Range") == "" {
+		return
+	}
+	key := f.Req.URL.String()
+	val := validator(resp.Header)
+	if val == "" {
+		return
+	}
+
+	start, total := int64(0), int64(len(f.bts))
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var end int64
+		var ok bool
+		start, end, total, ok = parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || end-start+1 != int64(len(f.bts)) {
+			return
+		}
+	case http.StatusOK:
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, found := c.objects[key]
+	if !found || o.validator != val || resp.StatusCode == http.StatusOK {
+		if found {
+			c.size -= o.size()
+			f.event("cache", "cached ranges dropped; object changed")
+		}
+		o = &rangeObject{validator: val, total: total}
+		c.objects[key] = o
+	}
+	if total >= 0 {
+		o.total = total
+	}
+	o.header = resp.Header.Clone()
+	o.header.Del("Content-Range")
+	o.header.Del("Content-Length")
+	o.used = time.Now()
+
+	c.size -= o.size()
+	o.spans = stitch(o.spans, rangeSpan{start: start, data: append([]byte(nil), f.bts...)})
+	c.size += o.size()
+	c.evict(key)
+}
+
+// stitch merges s into sorted spans; s wins over overlapping data
+func stitch(spans []rangeSpan, s rangeSpan) []rangeSpan {
+	start, end := s.start, s.start+int64(len(s.data))
+	ret, touching := []rangeSpan{}, []rangeSpan{}
+	for _, sp := range spans {
+		spEnd := sp.start + int64(len(sp.data))
+		if spEnd < s.start || sp.start > s.start+int64(len(s.data)) {
+			ret = append(ret, sp)
+			continue
+		}
+		touching = append(touching, sp)
+		if sp.start < start {
+			start = sp.start
+		}
+		if spEnd > end {
+			end = spEnd
+		}
+	}
+	merged := make([]byte, end-start)
+	for _, sp := range touching {
+		copy(merged[sp.start-start:], sp.data)
+	}
+	copy(merged[s.start-start:], s.data)
+	ret = append(ret, rangeSpan{start: start, data: merged})
+	sort.Slice(ret, func(i, k int) bool { return ret[i].start < ret[k].start })
+	return ret
+}
+
+// evict least recently used objects, except keep
+func (c *RangeCache) evict(keep string) {
+	for c.size > c.MaxBytes && len(c.objects) > 1 {
+		oldest := ""
+		for k, o := range c.objects {
+			if k != keep && (oldest == "" || o.used.Before(c.objects[oldest].used)) {
+				oldest = k
+			}
+		}
+		c.size -= c.objects[oldest].size()
+		delete(c.objects, oldest)
+	}
+}
@@ -0,0 +1,136 @@
+package fetch
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStitch(t *testing.T) {
+	span := func(start int64, s string) rangeSpan { return rangeSpan{start: start, data: []byte(s)} }
+	cases := []struct {
+		name  string
+		spans []rangeSpan
+		add   rangeSpan
+		want  []rangeSpan
+	}{
+		{"disjoint", []rangeSpan{span(0, "ab")}, span(5, "fg"),
+			[]rangeSpan{span(0, "ab"), span(5, "fg")}},
+		{"adjacent before", []rangeSpan{span(2, "cd")}, span(0, "ab"),
+			[]rangeSpan{span(0, "abcd")}},
+		{"adjacent after", []rangeSpan{span(0, "ab")}, span(2, "cd"),
+			[]rangeSpan{span(0, "abcd")}},
+		{"overlapping, new data wins", []rangeSpan{span(0, "abcd")}, span(2, "CDef"),
+			[]rangeSpan{span(0, "abCDef")}},
+		{"contained", []rangeSpan{span(0, "abcdef")}, span(2, "CD"),
+			[]rangeSpan{span(0, "abCDef")}},
+		{"bridging two spans", []rangeSpan{span(0, "ab"), span(4, "ef"), span(9, "j")}, span(2, "cd"),
+			[]rangeSpan{span(0, "abcdef"), span(9, "j")}},
+	}
+	for _, tc := range cases {
+		got := stitch(tc.spans, tc.add)
+		if len(got) != len(tc.want) {
+			t.Errorf("%v: got %v spans, want %v", tc.name, len(got), len(tc.want))
+			continue
+		}
+		for i := range got {
+			if got[i].start != tc.want[i].start || !bytes.Equal(got[i].data, tc.want[i].data) {
+				t.Errorf("%v: span %v is %v %q, want %v %q", tc.name, i,
+					got[i].start, got[i].data, tc.want[i].start, tc.want[i].data)
+			}
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		h          string
+		start, end int64
+		ok         bool
+	}{
+		{"bytes=0-9", 0, 9, true},
+		{"bytes=10-", 10, -1, true},
+		{"bytes=-5", -5, -1, true},
+		{"bytes=9-0", 0, 0, false},
+		{"bytes=0-1,5-6", 0, 0, false},
+		{"bytes=-0", 0, 0, false},
+		{"items=0-9", 0, 0, false},
+	}
+	for _, tc := range cases {
+		start, end, ok := parseRange(tc.h)
+		if ok != tc.ok || ok && (start != tc.start || end != tc.end) {
+			t.Errorf("%v: got %v %v %v, want %v %v %v", tc.h, start, end, ok, tc.start, tc.end, tc.ok)
+		}
+	}
+}
+
+func TestRangeCacheServe(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	var origin []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin = append(origin, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	rc := NewRangeCache(0)
+	steps := []struct {
+		rng      string
+		want     string
+		fromOrig bool
+	}{
+		{"bytes=0-9", content[0:10], true},
+		{"bytes=5-19", content[5:20], true},    // overlapping; only partly cached
+		{"bytes=10-14", content[10:15], false}, // within the stitched span
+		{"bytes=20-29", content[20:30], true},  // adjacent
+		{"bytes=0-29", content[0:30], false},   // adjacent spans stitched
+		{"bytes=-5", content[95:], true},       // suffix
+		{"bytes=-3", content[97:], false},      // suffix within the cached tail
+		{"bytes=98-", content[98:], false},     // open range within the cached tail
+		{"bytes=90-99", content[90:100], true}, // overlapping the tail
+		{"bytes=92-96", content[92:97], false},
+	}
+	for _, st := range steps {
+		before := len(origin)
+		j := &Job{URL: srv.URL, RangeCache: rc, Headers: http.Header{"Range": {st.rng}}}
+		j.Fetch()
+		if j.Err != nil || j.Status != http.StatusPartialContent {
+			t.Fatalf("%v: status %v, err %v", st.rng, j.Status, j.Err)
+		}
+		if string(j.Bytes()) != st.want {
+			t.Errorf("%v: got %q, want %q", st.rng, j.Bytes(), st.want)
+		}
+		if fromOrig := len(origin) > before; fromOrig != st.fromOrig {
+			t.Errorf("%v: from origin %v, want %v", st.rng, fromOrig, st.fromOrig)
+		}
+	}
+}
+
+// A changed object replaces the cached ranges of the old one.
+func TestRangeCacheChanged(t *testing.T) {
+	content, etag := strings.Repeat("a", 100), `"v1"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	rc := NewRangeCache(0)
+	fetch := func(rng string) *Job {
+		j := &Job{URL: srv.URL, RangeCache: rc, Headers: http.Header{"Range": {rng}}}
+		j.Fetch()
+		return j
+	}
+	fetch("bytes=0-9")
+	content, etag = strings.Repeat("b", 100), `"v2"`
+	if j := fetch("bytes=5-14"); j.Status != http.StatusOK || j.Bytes()[0] != 'b' {
+		t.Fatalf("If-Range mismatch: status %v, body %.10q", j.Status, j.Bytes())
+	}
+	if j := fetch("bytes=0-4"); string(j.Bytes()) != "bbbbb" {
+		t.Errorf("got %q from the old version", j.Bytes())
+	}
+}
@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SecretParams are query parameters redacted from urls
+// in Msg, events, redirect hops and errors.
+// Job.RedactParams adds to them.
+var SecretParams = []string{
+	"access_token", "api_key", "apikey", "auth", "client_secret", "code",
+	"key", "password", "pwd", "secret", "sig", "signature", "token",
+	"x-amz-credential", "x-amz-security-token", "x-amz-signature",
+	"x-goog-credential", "x-goog-signature",
+}
+
+const redacted = "REDACTED"
+
+var urlInText = regexp.MustCompile(`(?i)\b(https?|wss?|ftp|socks5)://[^\s"'<>]+`)
+
+func (f *Job) secretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range SecretParams {
+		if name == p {
+			return true
+		}
+	}
+	for _, p := range f.RedactParams {
+		if name == strings.ToLower(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL removes userinfo and secret query parameters.
+// Urls without secrets are returned unchanged - not re-encoded.
+func (f *Job) redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	changed := false
+	if u.User != nil {
+		u.User = url.User(redacted) // tokens often come as user name
+		changed = true
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for name := range q {
+			if f.secretParam(name) {
+				q.Set(name, redacted)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return u.String()
+}
+
+// RedactURL removes userinfo and SecretParams from u.
+func RedactURL(u string) string {
+	return (&Job{}).redactURL(u)
+}
+
+// redact all urls in a text
+func (f *Job) redact(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	return urlInText.ReplaceAllStringFunc(s, f.redactURL)
+}
+
+// redactedError keeps errors.Is and errors.As working
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+func (f *Job) redactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := f.redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
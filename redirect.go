@@ -0,0 +1,206 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// RedirectPolicy governs which redirects a Job follows.
+// Every hop - followed or not - is recorded in Job.Redirects.
+//
+// Typical modes:
+//
+//	RedirectPolicy{}                                                   // follow up to 10 hops anywhere
+//	RedirectPolicy{SameHost: true}                                     // follow, but never leave the host
+//	RedirectPolicy{Refuse: true}                                       // refuse all, but trailing slash additions
+//	RedirectPolicy{Refuse: true, Benign: []RedirectRule{HTTPSUpgrade}} // scheme upgrades only
+type RedirectPolicy struct {
+	Refuse   bool           // call off upon redirects - except for Benign ones
+	MaxHops  int            // 0 means 10, -1 means none
+	SameHost bool           // call off upon redirects to other host names
+	Benign   []RedirectRule `json:"-"` // followed despite Refuse; nil means TrailingSlash only
+}
+
+// RedirectRule identifies harmless redirects,
+// which are followed even if Refuse is set.
+type RedirectRule struct {
+	Name  string
+	Match func(from, to *url.URL) bool
+}
+
+var (
+	// TrailingSlash allows /gesundheit => /gesundheit/
+	TrailingSlash = RedirectRule{"trailing-slash", func(from, to *url.URL) bool {
+		return from.Host == to.Host && from.Scheme == to.Scheme && to.Path == from.Path+"/"
+	}}
+	// HTTPSUpgrade allows http://host/path => https://host/path
+	HTTPSUpgrade = RedirectRule{"https-upgrade", func(from, to *url.URL) bool {
+		return from.Scheme == "http" && to.Scheme == "https" &&
+			from.Hostname() == to.Hostname() && normPath(from.Path) == normPath(to.Path)
+	}}
+	// WWWPrefix allows example.com => www.example.com and vice versa
+	WWWPrefix = RedirectRule{"www-prefix", func(from, to *url.URL) bool {
+		a, b := from.Hostname(), to.Hostname()
+		return from.Scheme == to.Scheme && normPath(from.Path) == normPath(to.Path) &&
+			(a == "www."+b || b == "www."+a)
+	}}
+)
+
+func normPath(p string) string {
+	return "/" + strings.TrimPrefix(p, "/")
+}
+
+// RedirectHop is one step of a redirect chain
+type RedirectHop struct {
+	Status    int
+	From      string
+	To        string
+	SetCookie bool          // the redirect response set cookies
+	Elapsed   time.Duration // since start of the fetch
+	Followed  bool
+	Stripped  []string // sensitive headers not sent to this hop
+}
+
+func (h RedirectHop) String() string {
+	verb := "followed"
+	if !h.Followed {
+		verb = "refused"
+	}
+	return fmt.Sprintf("%v %v -> %v %v after %v", h.Status, h.From, h.To, verb, h.Elapsed)
+}
+
+// checkRedirect is the http.Client.CheckRedirect of a job
+func (f *Job) checkRedirect(req *http.Request, via []*http.Request) error {
+
+	hop := RedirectHop{
+		From:    f.redactURL(via[len(via)-1].URL.String()),
+		To:      f.redactURL(req.URL.String()),
+		Elapsed: time.Since(f.started),
+	}
+	if req.Response != nil {
+		hop.Status = req.Response.StatusCode
+		hop.SetCookie = len(req.Response.Header["Set-Cookie"]) > 0
+	}
+
+	err := f.redirectAllowed(req, via)
+	hop.Followed = err == nil
+	if hop.Followed {
+		hop.Stripped = f.scopeHeaders(req, via)
+	}
+	f.Redirects = append(f.Redirects, hop)
+	f.event("redirect", "%v", hop)
+	return err
+}
+
+// Redirect scopes for credentials;
+// Cookie, Authorization, Proxy-Authorization,
+// SecretHeaders and SensitiveHeaders are only
+// re-sent to hops within the scope of the original request.
+const (
+	ScopeDomain = iota // same domain or subdomain - the stdlib behavior
+	ScopeHost          // same host name
+	ScopeOrigin        // same scheme, host and port
+)
+
+func (f *Job) sensitiveHeaders() []string {
+	hdrs := []string{"Authorization", "Cookie", "Proxy-Authorization"}
+	for hdr := range f.SecretHeaders {
+		hdrs = append(hdrs, hdr)
+	}
+	return append(hdrs, f.SensitiveHeaders...)
+}
+
+func inScope(scope int, orig, to *url.URL) bool {
+	switch scope {
+	case ScopeOrigin:
+		return orig.Scheme == to.Scheme && orig.Host == to.Host
+	case ScopeHost:
+		return orig.Hostname() == to.Hostname()
+	}
+	a, b := orig.Hostname(), to.Hostname()
+	return a == b || strings.HasSuffix(b, "."+a)
+}
+
+// scopeHeaders removes sensitive headers for out of scope hops
+// and reports them - including those the stdlib removed itself.
+func (f *Job) scopeHeaders(req *http.Request, via []*http.Request) []string {
+	stripped := []string{}
+	keep := inScope(f.RedirectScope, via[0].URL, req.URL)
+	for _, hdr := range f.sensitiveHeaders() {
+		if via[0].Header.Get(hdr) == "" {
+			continue
+		}
+		if keep && req.Header.Get(hdr) != "" {
+			continue
+		}
+		req.Header.Del(hdr)
+		stripped = append(stripped, hdr)
+	}
+	if len(stripped) > 0 {
+		f.event("redirect", "headers %v not sent to %v", stripped, req.URL.Host)
+	}
+	return stripped
+}
+
+func (f *Job) maxRedirects() int {
+	if f.Redirect.MaxHops == 0 {
+		return 10
+	}
+	return f.Redirect.MaxHops
+}
+
+// redirectTime is the time spent up to the last followed redirect
+func (f *Job) redirectTime() time.Duration {
+	for i := len(f.Redirects) - 1; i >= 0; i-- {
+		if f.Redirects[i].Followed {
+			return f.Redirects[i].Elapsed
+		}
+	}
+	return 0
+}
+
+func (f *Job) redirectAllowed(req *http.Request, via []*http.Request) error {
+
+	if max := f.maxRedirects(); len(via) > max {
+		return fmt.Errorf("%w: stopped after %v redirects", ErrTooManyRedirects, max)
+	}
+
+	if f.HostHeader != "" {
+		// Keep the host header only while we stay on the dialed host.
+		// The stdlib would keep it for relative redirects only.
+		if req.URL.Host == via[0].URL.Host {
+			req.Host = f.HostHeader
+		} else {
+			req.Host = ""
+			f.log(slog.LevelInfo, "redirect - host header dropped", "host", req.URL.Host)
+		}
+	}
+
+	if f.Redirect.SameHost && req.URL.Hostname() != via[0].URL.Hostname() {
+		return fmt.Errorf("%w: %v -> %v leaves the host", ErrRedirectBlocked, via[len(via)-1].URL, req.URL)
+	}
+
+	if f.Redirect.Refuse {
+		from := via[len(via)-1].URL
+		rules := f.Redirect.Benign
+		if rules == nil {
+			rules = []RedirectRule{TrailingSlash}
+		}
+		for _, rule := range rules {
+			if rule.Match(from, req.URL) {
+				f.event("redirect", "%v -> %v allowed as %v", from, req.URL, rule.Name)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %v -> %v", ErrRedirectBlocked, from, req.URL)
+	}
+
+	return nil
+}
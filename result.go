@@ -0,0 +1,171 @@
+package fetch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// JobResult is the serializable record of a Job.
+// It holds everything needed to re-execute the identical request,
+// except secret values - only the names in SecretHeaders are kept.
+type JobResult struct {
+	Method        string
+	URL           string
+	Header        http.Header       `json:",omitempty"`
+	Body          []byte            `json:",omitempty"` // request body, if it could be recovered
+	Response      []byte            `json:",omitempty"` // response body, see ResultWithBody()
+	SecretHeaders map[string]string `json:",omitempty"`
+	AuthScheme    string            `json:",omitempty"` // Basic, Bearer or Digest; the credentials are not kept
+
+	Timeout        time.Duration
+	Redirect       RedirectPolicy   // without Benign rules
+	ForceProtocol  string           `json:",omitempty"`
+	ForceHttps     bool             `json:",omitempty"`
+	HostHeader     string           `json:",omitempty"`
+	LocalAddr      string           `json:",omitempty"`
+	MaxBytes       int64            `json:",omitempty"`
+	MaxBytesByType map[string]int64 `json:",omitempty"`
+
+	TraceID    string `json:",omitempty"`
+	Status     int
+	RespHeader http.Header   `json:",omitempty"`
+	Redirects  []RedirectHop `json:",omitempty"`
+	Mod        time.Time
+	Msg        string  `json:",omitempty"`
+	Events     []Event `json:",omitempty"`
+	Err        string  `json:",omitempty"`
+}
+
+// Result records the job.
+// Call it after Fetch().
+func (j *Job) Result() JobResult {
+	r := JobResult{
+		URL:            j.URL,
+		SecretHeaders:  j.SecretHeaders,
+		Timeout:        j.Timeout,
+		Redirect:       j.Redirect,
+		ForceProtocol:  j.ForceProtocol,
+		ForceHttps:     j.ForceHttps,
+		HostHeader:     j.HostHeader,
+		LocalAddr:      j.LocalAddr,
+		MaxBytes:       j.MaxBytes,
+		MaxBytesByType: j.MaxBytesByType,
+		TraceID:        j.TraceID,
+		Status:         j.Status,
+		Redirects:      j.Redirects,
+		RespHeader:     j.RespHeader,
+		Mod:            j.Mod,
+		Msg:            j.Msg,
+		Events:         j.Events,
+	}
+	if j.Err != nil {
+		r.Err = j.Err.Error()
+	}
+	if j.Req == nil {
+		r.Method = "GET"
+		return r
+	}
+
+	r.Method = j.Req.Method
+	r.URL = j.Req.URL.String()
+	r.Header = j.callerHeader()
+	for hdr := range j.SecretHeaders {
+		r.Header.Del(hdr) // resolved values must not be persisted
+	}
+	switch {
+	case j.authz != "":
+		r.AuthScheme = j.authzScheme
+		r.Header.Del("Authorization")
+	case j.digestUser != "":
+		r.AuthScheme = "Digest"
+		r.Header.Del("Authorization")
+	}
+	if j.Req.GetBody != nil {
+		if rc, err := j.Req.GetBody(); err == nil {
+			r.Body, _ = ioutil.ReadAll(rc)
+			rc.Close()
+			r.Body = j.Scrubber.Scrub(r.Body)
+		}
+	}
+	return r
+}
+
+// callerHeader are the request headers set by the caller -
+// those of a prebuilt Req and Headers - without the ones we added,
+// i.e. traceparent, Accept-Encoding, validators or ranges.
+func (j *Job) callerHeader() http.Header {
+	h := j.origHeader.Clone()
+	if j.origHeader == nil { // not fetched yet
+		h = j.Req.Header.Clone()
+	}
+	if h == nil {
+		h = http.Header{}
+	}
+	for k, vals := range j.Headers {
+		if h.Get(k) == "" {
+			h[http.CanonicalHeaderKey(k)] = append([]string(nil), vals...)
+		}
+	}
+	return h
+}
+
+// ResultWithBody additionally records the response body,
+// passed through the Scrubber.
+func (j *Job) ResultWithBody() JobResult {
+	r := j.Result()
+	r.Response = j.Scrubber.Scrub(j.bts)
+	return r
+}
+
+// Replay reconstructs the request recorded in r.
+// Secrets, credentials, AeReq and benign redirect rules are not recorded;
+// set them before calling Fetch() - AuthScheme tells which of
+// BasicAuth(), BearerToken() or DigestAuth() the original used.
+func Replay(r JobResult) (*Job, error) {
+	var body *bytes.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequest(r.Method, r.URL, body)
+	} else {
+		req, err = http.NewRequest(r.Method, r.URL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	return &Job{
+		URL:            r.URL,
+		Req:            req,
+		SecretHeaders:  r.SecretHeaders,
+		Timeout:        r.Timeout,
+		Redirect:       r.Redirect,
+		ForceProtocol:  r.ForceProtocol,
+		ForceHttps:     r.ForceHttps,
+		HostHeader:     r.HostHeader,
+		LocalAddr:      r.LocalAddr,
+		MaxBytes:       r.MaxBytes,
+		MaxBytesByType: r.MaxBytesByType,
+	}, nil
+}
+
+// saveCallerHeader keeps the headers of a prebuilt Req,
+// before the first fetch adds ours; see Result().
+func (f *Job) saveCallerHeader() {
+	if f.origHeader != nil {
+		return
+	}
+	f.origHeader = http.Header{}
+	if f.Req != nil {
+		for k, vals := range f.Req.Header {
+			f.origHeader[k] = append([]string(nil), vals...)
+		}
+	}
+}
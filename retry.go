@@ -0,0 +1,229 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var defaultRetryStatus = []int{429, 502, 503, 504}
+
+// retryAfterAttempt decides on another attempt
+// and returns the backoff.
+// The job is reset for the next attempt.
+func (f *Job) retryAfterAttempt(ctx context.Context) (time.Duration, bool) {
+
+	if f.local() {
+		return 0, false
+	}
+
+	if wait, handled, retry := f.challengeAttempt(ctx); handled {
+		return wait, retry
+	}
+
+	if f.Attempts-f.attemptBase >= f.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	reason := ""
+	if f.Err != nil {
+		if !isTransient(f.Err) {
+			return 0, false
+		}
+		// only a failed dial proves the request never left
+		if !isDialError(f.Err) && !isIdempotent(f.Req) {
+			f.log(slog.LevelWarn, "cannot retry: the request may have been processed", "method", f.Req.Method)
+			return 0, false
+		}
+		reason = f.Err.Error()
+	} else {
+		if !f.retriableStatus(f.Status) {
+			return 0, false
+		}
+		reason = fmt.Sprintf("status %v", f.Status)
+	}
+
+	if !f.rewindBody() {
+		f.log(slog.LevelWarn, "cannot retry: request body is not replayable")
+		return 0, false
+	}
+
+	wait := f.backoff(f.Attempts - f.attemptBase)
+	if ra, ok := retryAfter(f.Status, f.RespHeader); ok {
+		f.RetryAfter = ra
+		if dl, hasDL := ctx.Deadline(); hasDL && time.Now().Add(ra).After(dl) {
+			ra = time.Until(dl)
+			if ra <= 0 {
+				return 0, false
+			}
+			f.log(slog.LevelWarn, "Retry-After exceeds the deadline; waiting until the deadline", "retry-after", f.RetryAfter, "wait", ra)
+		}
+		if f.Limiter != nil {
+			f.Limiter.Pause(targetHost(f), time.Now().Add(ra))
+		}
+		wait = ra
+	}
+	if stretched := f.Storm.spread(targetHost(f), wait); stretched > wait {
+		f.event("storm", "retry storm against %v; delayed by another %v", targetHost(f), (stretched - wait).Round(time.Millisecond))
+		wait = stretched
+	}
+	f.event("retry", "attempt %v failed with %v; next in %v", f.Attempts, reason, wait)
+	f.log(slog.LevelWarn, "attempt failed; retrying", "attempt", f.Attempts, "reason", reason, "wait", wait)
+	if f.onRetry != nil {
+		f.onRetry(f, reason, wait)
+	}
+	f.resetResponse()
+	return wait, true
+}
+
+// retriableStatus - TerminalStatus takes precedence over RetryStatus
+func (f *Job) retriableStatus(status int) bool {
+	for _, c := range f.TerminalStatus {
+		if c == status {
+			return false
+		}
+	}
+	codes := f.RetryStatus
+	if codes == nil {
+		codes = defaultRetryStatus
+	}
+	for _, c := range codes {
+		if c == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header of 429 and 503 responses -
+// either seconds or an http date.
+func retryAfter(status int, h http.Header) (time.Duration, bool) {
+	if status != 429 && status != 503 {
+		return 0, false
+	}
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// StatusRange lists the codes from lo to hi, i.e. StatusRange(500, 504).
+func StatusRange(lo, hi int) []int {
+	codes := []int{}
+	for c := lo; c <= hi; c++ {
+		codes = append(codes, c)
+	}
+	return codes
+}
+
+// isTransient covers network failures worth another try
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	return isDialError(err) || isReuseRace(err) || maybeProcessed(err)
+}
+
+// maybeProcessed covers timeouts and stalled bodies
+func maybeProcessed(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrBodyStalled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// backoff for the given attempt, starting at 1
+func (f *Job) backoff(attempt int) time.Duration {
+	base, limit := f.BackoffBase, f.BackoffCap
+	if base <= 0 {
+		base = 500 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = 30 * time.Second
+	}
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	if f.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * f.Jitter * float64(d))
+	}
+	return d
+}
+
+// rewindBody restores a consumed request body
+func (f *Job) rewindBody() bool {
+	if f.Req == nil || f.Req.Body == nil || f.Req.Body == http.NoBody {
+		return true
+	}
+	if f.Req.GetBody == nil {
+		return false
+	}
+	body, err := f.Req.GetBody()
+	if err != nil {
+		return false
+	}
+	f.Req.Body = body
+	return true
+}
+
+// resetResponse clears the results of a previous attempt
+func (f *Job) resetResponse() {
+	if f.stream != nil {
+		f.stream.Close()
+		f.stream = nil
+	}
+	f.Err = nil
+	f.Status = 0
+	f.RespHeader = nil
+	f.bts = nil
+	f.BytesOnWire, f.BytesDecoded, f.Encoding = 0, 0, ""
+	f.CDN = nil
+	f.TLS = nil
+	f.Archived = nil
+	f.NotModified, f.ETag = false, ""
+	f.Text = nil
+	f.Skipped = ""
+	if f.SpillPath != "" {
+		f.dropSpill(nil)
+	}
+}
+
+// sleepContext returns false, if ctx was done before d elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
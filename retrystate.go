@@ -0,0 +1,79 @@
+package fetch
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// PendingJob is a job of a running batch
+// that has not finished yet.
+type PendingJob struct {
+	URL         string
+	State       string    // queued, running or waiting
+	Attempt     int       // attempts made so far; the backoff level
+	NextAttempt time.Time `json:",omitempty"` // when waiting
+	LastFailure string    `json:",omitempty"`
+}
+
+func (b *Batch) track(j *Job, state string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if state == "" {
+		delete(b.pending, j)
+		return
+	}
+	if b.pending == nil {
+		b.pending = map[*Job]*PendingJob{}
+	}
+	p, ok := b.pending[j]
+	if !ok {
+		p = &PendingJob{URL: j.URL}
+		b.pending[j] = p
+	}
+	p.State = state
+	if state == "running" {
+		p.NextAttempt = time.Time{}
+	}
+}
+
+// onRetry is hooked into each job of the batch
+func (b *Batch) onRetry(j *Job, reason string, wait time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.pending[j]; ok {
+		p.State = "waiting"
+		p.Attempt = j.Attempts
+		p.NextAttempt = time.Now().Add(wait)
+		p.LastFailure = reason
+	}
+}
+
+// Pending returns the unfinished jobs,
+// those waiting for a retry first, by next attempt.
+// It is safe to call while the batch is running.
+func (b *Batch) Pending() []PendingJob {
+	b.mu.Lock()
+	ret := make([]PendingJob, 0, len(b.pending))
+	for _, p := range b.pending {
+		ret = append(ret, *p)
+	}
+	b.mu.Unlock()
+
+	rank := map[string]int{"waiting": 0, "running": 1, "queued": 2}
+	sort.Slice(ret, func(i, k int) bool {
+		if ret[i].State != ret[k].State {
+			return rank[ret[i].State] < rank[ret[k].State]
+		}
+		if !ret[i].NextAttempt.Equal(ret[k].NextAttempt) {
+			return ret[i].NextAttempt.Before(ret[k].NextAttempt)
+		}
+		return ret[i].URL < ret[k].URL
+	})
+	return ret
+}
+
+// PendingJSON answers "when will X be retried?"
+func (b *Batch) PendingJSON() ([]byte, error) {
+	return json.MarshalIndent(b.Pending(), "", "  ")
+}
@@ -0,0 +1,68 @@
+package fetch
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// isIdempotent per RFC 7231 - or by explicit idempotency key
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// isReuseRace identifies a server closing a keep-alive connection,
+// while we were sending on it.
+func isReuseRace(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	return strings.Contains(err.Error(), "server closed idle connection")
+}
+
+// retryReusedConn retries once on a fresh connection,
+// if an idempotent request failed on a reused one.
+// The stdlib retries only some of these cases.
+func (f *Job) retryReusedConn(client *http.Client, t *jobTrace, err error) (*http.Response, error) {
+
+	if !t.lastReused() || !isReuseRace(err) || !isIdempotent(f.Req) {
+		return nil, err
+	}
+	if f.Req.Body != nil && f.Req.Body != http.NoBody {
+		if f.Req.GetBody == nil {
+			return nil, err
+		}
+		body, errBody := f.Req.GetBody()
+		if errBody != nil {
+			return nil, err
+		}
+		f.Req.Body = body
+	}
+
+	// a transport without keep-alives guarantees a fresh connection
+	fresh := *client
+	var base http.RoundTripper = client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if tr, ok := base.(*http.Transport); ok {
+		tr2 := tr.Clone()
+		tr2.DisableKeepAlives = true
+		fresh.Transport = tr2
+		defer tr2.CloseIdleConnections()
+	}
+
+	f.event("retry", "reused connection failed with %v; retrying on fresh connection", err)
+	resp, err2nd := fresh.Do(f.Req)
+	if err2nd != nil {
+		return nil, err2nd
+	}
+	return resp, nil
+}
@@ -0,0 +1,68 @@
+package fetch
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+)
+
+// RewriteRule changes the URL before fetching,
+// i.e. to map production hosts to staging,
+// or to force a CDN hostname.
+// Either Hosts or Match is used.
+type RewriteRule struct {
+	Name    string
+	Hosts   map[string]string // host => replacement host; ports are kept
+	Match   *regexp.Regexp    // applied to the full URL
+	Replace string            // regexp.Expand syntax, i.e. "https://staging.$1"
+}
+
+func (r RewriteRule) apply(u *url.URL) (*url.URL, error) {
+	if r.Hosts != nil {
+		to, ok := r.Hosts[u.Hostname()]
+		if !ok {
+			return u, nil
+		}
+		u2 := *u
+		u2.Host = to
+		if port := u.Port(); port != "" {
+			u2.Host = to + ":" + port
+		}
+		return &u2, nil
+	}
+	if r.Match == nil || !r.Match.MatchString(u.String()) {
+		return u, nil
+	}
+	return url.Parse(r.Match.ReplaceAllString(u.String(), r.Replace))
+}
+
+// rewrite applies Rewrites in order, each on the result of its predecessor.
+// Runs once per url - not again for retries, but again for a mirror.
+func (f *Job) rewrite() error {
+	if len(f.Rewrites) == 0 || f.rewritten {
+		return nil
+	}
+	orig := f.Req.URL
+	u := orig
+	for i, r := range f.Rewrites {
+		u2, err := r.apply(u)
+		if err != nil {
+			return fmt.Errorf("rewrite rule %v %q: %v", i, r.Name, err)
+		}
+		if u2.String() != u.String() {
+			f.event("rewrite", "%v: %v => %v", r.Name, u, u2)
+		}
+		u = u2
+	}
+	f.rewritten = true
+	if u == orig {
+		return nil
+	}
+	if f.Req.Host == orig.Host {
+		f.Req.Host = "" // follow the new URL
+	}
+	f.Req.URL = u
+	f.log(slog.LevelInfo, "url rewritten", "url", u)
+	return nil
+}
@@ -0,0 +1,196 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/zew/util"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// RPCCodec encodes messages for Connect and gRPC-Web calls.
+type RPCCodec interface {
+	Name() string // "json" or "proto"
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(bts []byte, v interface{}) error
+}
+
+// JSONCodec uses protojson for proto messages,
+// encoding/json for everything else.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.Marshal(m)
+	}
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(bts []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.Unmarshal(bts, m)
+	}
+	return json.Unmarshal(bts, v)
+}
+
+// ProtoCodec requires proto messages.
+type ProtoCodec struct{}
+
+func (ProtoCodec) Name() string { return "proto" }
+
+func (ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is no proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtoCodec) Unmarshal(bts []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is no proto.Message", v)
+	}
+	return proto.Unmarshal(bts, m)
+}
+
+type RPCProtocol int
+
+const (
+	Connect RPCProtocol = iota
+	GRPCWeb
+)
+
+// RPCError is an error status returned by the server.
+type RPCError struct {
+	Code    string // connect style, i.e. "not_found"
+	Message string
+	Status  int // http status
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc %v: %v", e.Code, e.Message)
+}
+
+// gRPC status numbers to connect code names
+var rpcCodes = []string{
+	"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded",
+	"not_found", "already_exists", "permission_denied", "resource_exhausted",
+	"failed_precondition", "aborted", "out_of_range", "unimplemented",
+	"internal", "unavailable", "data_loss", "unauthenticated",
+}
+
+// RPCUnary issues a unary call of procedure - i.e. "/acme.user.v1.UserService/GetUser" -
+// against the base URL in j.URL.
+// Since the call is an ordinary Job, all its settings apply.
+func RPCUnary(j *Job, protocol RPCProtocol, procedure string, codec RPCCodec, in, out interface{}) error {
+
+	bts, err := codec.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	u, err := util.UrlParseImproved(strings.TrimSuffix(j.URL, "/") + procedure)
+	if err != nil {
+		return err
+	}
+
+	if protocol == GRPCWeb {
+		frame := make([]byte, 5, 5+len(bts))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(bts)))
+		bts = append(frame, bts...)
+	}
+
+	j.Req, err = http.NewRequest("POST", u.String(), bytes.NewReader(bts))
+	if err != nil {
+		return err
+	}
+	if protocol == GRPCWeb {
+		j.Req.Header.Set("Content-Type", "application/grpc-web+"+codec.Name())
+		j.Req.Header.Set("X-Grpc-Web", "1")
+	} else {
+		j.Req.Header.Set("Content-Type", "application/"+codec.Name())
+		j.Req.Header.Set("Connect-Protocol-Version", "1")
+	}
+
+	j.Fetch()
+	if j.Err != nil {
+		return j.Err
+	}
+
+	if protocol == GRPCWeb {
+		return grpcWebResponse(j, codec, out)
+	}
+
+	if j.Status != http.StatusOK {
+		e := &RPCError{Status: j.Status}
+		if err := json.Unmarshal(j.bts, e); err != nil || e.Code == "" {
+			e.Code = "unknown"
+			e.Message = util.Ellipsoider(string(j.bts), 200)
+		}
+		return e
+	}
+	return codec.Unmarshal(j.bts, out)
+}
+
+// grpcWebResponse splits the body into length prefixed frames.
+// Data frames have flag 0x00, the trailer frame has flag 0x80.
+// Trailers-only responses carry the status in the http header instead.
+func grpcWebResponse(j *Job, codec RPCCodec, out interface{}) error {
+
+	if j.Status != http.StatusOK {
+		return &RPCError{Code: "unknown", Message: fmt.Sprintf("http status %v", j.Status), Status: j.Status}
+	}
+
+	var msg []byte
+	trailer := http.Header{}
+	for k, v := range j.RespHeader {
+		trailer[k] = v
+	}
+
+	body := j.bts
+	for len(body) >= 5 {
+		flag := body[0]
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			return fmt.Errorf("grpc-web frame truncated")
+		}
+		payload := body[5 : 5+size]
+		body = body[5+size:]
+		if flag&0x80 != 0 {
+			for _, line := range strings.Split(string(payload), "\r\n") {
+				kv := strings.SplitN(line, ":", 2)
+				if len(kv) == 2 {
+					trailer.Set(textproto.TrimString(kv[0]), textproto.TrimString(kv[1]))
+				}
+			}
+			continue
+		}
+		msg = payload
+	}
+
+	st := trailer.Get("Grpc-Status")
+	if st == "" {
+		return fmt.Errorf("grpc-web response without grpc-status")
+	}
+	code, err := strconv.Atoi(st)
+	if err != nil || code < 0 || code >= len(rpcCodes) {
+		code = 2 // unknown
+	}
+	if code != 0 {
+		return &RPCError{Code: rpcCodes[code], Message: trailer.Get("Grpc-Message"), Status: j.Status}
+	}
+	if msg == nil {
+		return fmt.Errorf("grpc-web response without message")
+	}
+	return codec.Unmarshal(msg, out)
+}
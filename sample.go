@@ -0,0 +1,27 @@
+package fetch
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Sampled decides stably, whether url belongs to
+// a sample of percent of all urls.
+// The same url and seed always yield the same decision,
+// thus cycles compare the same subset over time.
+// Change the seed to rotate the sample.
+func Sampled(url string, percent float64, seed string) bool {
+	if percent >= 100 {
+		return true
+	}
+	if percent <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	h.Write([]byte{0})
+	h.Write([]byte(url))
+	sum := h.Sum(nil)
+	bucket := binary.BigEndian.Uint64(sum) % 10000
+	return float64(bucket) < percent*100
+}
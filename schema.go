@@ -0,0 +1,84 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaViolation is a single JSON Schema violation
+// within a response body.
+type SchemaViolation struct {
+	Path    string // JSON pointer into the response, i.e. /items/3/price
+	Keyword string // JSON pointer into the schema
+	Message string
+}
+
+// SchemaError is returned, if a response does not
+// match Job.Schema. Upstream contract changes
+// show up here instead of silently corrupting downstream data.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", v.Path, v.Message))
+	}
+	return fmt.Sprintf("response violates schema: %v", strings.Join(msgs, "; "))
+}
+
+// CompileSchema is a convenience for jsonschema.CompileString.
+func CompileSchema(schema string) (*jsonschema.Schema, error) {
+	return jsonschema.CompileString("schema.json", schema)
+}
+
+// validateSchema checks the response body against f.Schema.
+// Non 2xx responses are not validated - they rarely
+// follow the contract anyway.
+func (f *Job) validateSchema() error {
+	if f.Schema == nil || f.Status < 200 || f.Status > 299 {
+		return nil
+	}
+	return ValidateSchema(f.Schema, f.bts)
+}
+
+// ValidateSchema validates JSON bytes against schema.
+func ValidateSchema(schema *jsonschema.Schema, bts []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(bts))
+	dec.UseNumber() // jsonschema requires json.Number for exact numeric checks
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return &SchemaError{Violations: []SchemaViolation{{Path: "", Message: err.Error()}}}
+	}
+	err := schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	se := &SchemaError{}
+	collectViolations(ve, se)
+	return se
+}
+
+// collectViolations flattens the cause tree down to its leaves
+func collectViolations(ve *jsonschema.ValidationError, se *SchemaError) {
+	if len(ve.Causes) == 0 {
+		se.Violations = append(se.Violations, SchemaViolation{
+			Path:    ve.InstanceLocation,
+			Keyword: ve.KeywordLocation,
+			Message: ve.Message,
+		})
+		return
+	}
+	for _, c := range ve.Causes {
+		collectViolations(c, se)
+	}
+}
@@ -0,0 +1,139 @@
+package fetch
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scorecard is the health of one upstream host.
+type Scorecard struct {
+	Host          string
+	Requests      int
+	Failures      int // errors and 5xx
+	SuccessRate   float64
+	MedianLatency time.Duration // over the most recent requests
+	LastFailure   time.Time
+	LastError     string
+}
+
+type hostStats struct {
+	card      Scorecard
+	latencies []time.Duration // ring buffer
+	next      int
+}
+
+// Scorecards aggregate health per host;
+// share one instance across all jobs.
+type Scorecards struct {
+	mu     sync.Mutex
+	window int
+	hosts  map[string]*hostStats
+}
+
+// NewScorecards keeps the latencies of the last window requests per host.
+func NewScorecards(window int) *Scorecards {
+	if window < 1 {
+		window = 100
+	}
+	return &Scorecards{window: window, hosts: map[string]*hostStats{}}
+}
+
+func jobHost(j *Job) string {
+	if j.Req != nil && j.Req.URL != nil {
+		return j.Req.URL.Hostname()
+	}
+	return ""
+}
+
+// Record adds a finished job.
+func (s *Scorecards) Record(j *Job) {
+	host := jobHost(j)
+	if host == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hs, ok := s.hosts[host]
+	if !ok {
+		hs = &hostStats{card: Scorecard{Host: host}}
+		s.hosts[host] = hs
+	}
+	hs.card.Requests++
+	if j.Err != nil || j.Status >= 500 {
+		hs.card.Failures++
+		hs.card.LastFailure = time.Now()
+		if j.Err != nil {
+			hs.card.LastError = j.Err.Error()
+		} else {
+			hs.card.LastError = fmt.Sprintf("status %v", j.Status)
+		}
+	}
+	if len(hs.latencies) < s.window {
+		hs.latencies = append(hs.latencies, j.Elapsed)
+	} else {
+		hs.latencies[hs.next] = j.Elapsed
+		hs.next = (hs.next + 1) % s.window
+	}
+}
+
+func (hs *hostStats) scorecard() Scorecard {
+	c := hs.card
+	c.SuccessRate = float64(c.Requests-c.Failures) / float64(c.Requests)
+	lats := append([]time.Duration(nil), hs.latencies...)
+	sort.Slice(lats, func(i, k int) bool { return lats[i] < lats[k] })
+	if len(lats) > 0 {
+		c.MedianLatency = lats[len(lats)/2]
+	}
+	return c
+}
+
+// Get returns the scorecard of a host.
+func (s *Scorecards) Get(host string) (Scorecard, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[host]
+	if !ok {
+		return Scorecard{}, false
+	}
+	return hs.scorecard(), true
+}
+
+// All returns all scorecards, worst success rate first.
+func (s *Scorecards) All() []Scorecard {
+	s.mu.Lock()
+	ret := make([]Scorecard, 0, len(s.hosts))
+	for _, hs := range s.hosts {
+		ret = append(ret, hs.scorecard())
+	}
+	s.mu.Unlock()
+	sort.Slice(ret, func(i, k int) bool {
+		if ret[i].SuccessRate != ret[k].SuccessRate {
+			return ret[i].SuccessRate < ret[k].SuccessRate
+		}
+		return ret[i].Host < ret[k].Host
+	})
+	return ret
+}
+
+// Report calls fn with All() every interval,
+// until the returned stop func is called.
+func (s *Scorecards) Report(interval time.Duration, fn func([]Scorecard)) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				fn(s.All())
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,91 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Scrubber removes tokens and personal data from bodies
+// before they are persisted into results, caches or logs.
+type Scrubber struct {
+	Patterns    []*regexp.Regexp // matches are replaced
+	JSONFields  []string         // values of these keys are replaced at any depth; case insensitive
+	Replacement string           // defaults to "[REDACTED]"
+}
+
+// DefaultScrubber covers e-mail addresses, JWTs, bearer tokens
+// and the usual credential fields.
+func DefaultScrubber() *Scrubber {
+	return &Scrubber{
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+			regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`),
+			regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/\-]+=*`),
+		},
+		JSONFields: []string{
+			"password", "passwd", "secret", "token", "access_token", "refresh_token",
+			"id_token", "api_key", "apikey", "authorization", "client_secret",
+		},
+	}
+}
+
+func (s *Scrubber) replacement() string {
+	if s.Replacement == "" {
+		return "[REDACTED]"
+	}
+	return s.Replacement
+}
+
+// Scrub returns a cleansed copy of bts.
+// JSON fields are only handled, if bts is valid JSON;
+// the document is then re-encoded with sorted keys.
+func (s *Scrubber) Scrub(bts []byte) []byte {
+	if s == nil || len(bts) == 0 {
+		return bts
+	}
+	if len(s.JSONFields) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(bts))
+		dec.UseNumber()
+		var doc interface{}
+		if err := dec.Decode(&doc); err == nil {
+			doc = s.scrubJSON(doc)
+			if out, err := json.Marshal(doc); err == nil {
+				bts = out
+			}
+		}
+	}
+	repl := []byte(s.replacement())
+	for _, rx := range s.Patterns {
+		bts = rx.ReplaceAllLiteral(bts, repl)
+	}
+	return bts
+}
+
+func (s *Scrubber) scrubJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if s.isField(k) {
+				t[k] = s.replacement()
+				continue
+			}
+			t[k] = s.scrubJSON(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = s.scrubJSON(val)
+		}
+	}
+	return v
+}
+
+func (s *Scrubber) isField(k string) bool {
+	for _, f := range s.JSONFields {
+		if strings.EqualFold(f, k) {
+			return true
+		}
+	}
+	return false
+}
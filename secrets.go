@@ -0,0 +1,148 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secrets provides credentials by name.
+// Jobs only carry secret names; values are looked up
+// at fetch time and never stored in the Job.
+type Secrets interface {
+	Get(name string) (string, error)
+}
+
+func (f *Job) secrets() Secrets {
+	if f.Secrets == nil {
+		return EnvSecrets{}
+	}
+	return f.Secrets
+}
+
+// EnvSecrets reads secrets from environment variables.
+type EnvSecrets struct {
+	Prefix string // i.e. "MYAPP_" - prepended to each name
+}
+
+func (s EnvSecrets) Get(name string) (string, error) {
+	val, ok := os.LookupEnv(s.Prefix + name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q not set", s.Prefix+name)
+	}
+	return val, nil
+}
+
+// FileSecrets reads one file per secret from Dir,
+// as mounted by docker or kubernetes.
+// A trailing newline is removed.
+type FileSecrets struct {
+	Dir string
+}
+
+func (s FileSecrets) Get(name string) (string, error) {
+	if name != filepath.Base(name) {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	bts, err := ioutil.ReadFile(filepath.Join(s.Dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(bts), "\r\n"), nil
+}
+
+// GoogleSecrets reads from Google Secret Manager
+// via its REST API.
+// The access token is taken from the metadata server,
+// thus it only works on GAE, GCE, Cloud Run and friends.
+type GoogleSecrets struct {
+	Project string
+	Version string        // defaults to "latest"
+	AeReq   *http.Request // passed on to the fetch jobs
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+var metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+func (s *GoogleSecrets) accessToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expiry) {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequest("GET", metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	j := Job{Req: req, AeReq: s.AeReq, Timeout: 5}
+	j.Fetch()
+	if j.Err != nil {
+		return "", j.Err
+	}
+	if j.Status != http.StatusOK {
+		return "", fmt.Errorf("metadata server status %v", j.Status)
+	}
+	tok := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(j.Bytes(), &tok); err != nil {
+		return "", err
+	}
+	s.token = tok.AccessToken
+	s.expiry = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+func (s *GoogleSecrets) Get(name string) (string, error) {
+	tok, err := s.accessToken()
+	if err != nil {
+		return "", fmt.Errorf("secret manager token: %v", err)
+	}
+	version := s.Version
+	if version == "" {
+		version = "latest"
+	}
+	u := fmt.Sprintf(
+		"https://secretmanager.googleapis.com/v1/projects/%v/secrets/%v/versions/%v:access",
+		s.Project, name, version,
+	)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	j := Job{Req: req, AeReq: s.AeReq}
+	j.Fetch()
+	if j.Err != nil {
+		return "", j.Err
+	}
+	if j.Status != http.StatusOK {
+		return "", fmt.Errorf("secret %q: status %v", name, j.Status)
+	}
+	resp := struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}{}
+	if err := json.Unmarshal(j.Bytes(), &resp); err != nil {
+		return "", err
+	}
+	bts, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(bts), nil
+}
@@ -0,0 +1,206 @@
+package fetch
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Credentials for signing S3 GET urls.
+// Endpoint is only needed for S3 compatible stores such as minio;
+// it enforces path style urls.
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // optional
+	Region          string
+	Endpoint        string // optional, i.e. "https://minio.local:9000"
+}
+
+// GCSCredentials for signing Google Cloud Storage GET urls.
+type GCSCredentials struct {
+	ClientEmail string
+	PrivateKey  []byte // PEM
+}
+
+// GCSCredentialsFromJSON reads a service account key file.
+func GCSCredentialsFromJSON(bts []byte) (GCSCredentials, error) {
+	sa := struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}{}
+	if err := json.Unmarshal(bts, &sa); err != nil {
+		return GCSCredentials{}, err
+	}
+	return GCSCredentials{ClientEmail: sa.ClientEmail, PrivateKey: []byte(sa.PrivateKey)}, nil
+}
+
+// SignedJob prepares a job for a signed url.
+// The request is prebuilt, bypassing url normalization,
+// which might re-encode the query and break the signature.
+func SignedJob(signedURL string) (*Job, error) {
+	req, err := http.NewRequest("GET", signedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Job{URL: signedURL, Req: req}, nil
+}
+
+// SignedURL returns a presigned AWS signature V4 GET url.
+func (c S3Credentials) SignedURL(bucket, key string, expires time.Duration) (string, error) {
+	return c.signedURL(bucket, key, expires, time.Now())
+}
+
+func (c S3Credentials) signedURL(bucket, key string, expires time.Duration, now time.Time) (string, error) {
+
+	now = now.UTC()
+	date := now.Format("20060102")
+	stamp := now.Format("20060102T150405Z")
+
+	scheme, host, path := "https", fmt.Sprintf("%v.s3.%v.amazonaws.com", bucket, c.Region), "/"+key
+	if c.Region == "us-east-1" {
+		host = bucket + ".s3.amazonaws.com" // the global endpoint
+	}
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil {
+			return "", err
+		}
+		scheme, host, path = u.Scheme, u.Host, "/"+bucket+"/"+key
+	}
+
+	scope := fmt.Sprintf("%v/%v/s3/aws4_request", date, c.Region)
+	q := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    c.AccessKeyID + "/" + scope,
+		"X-Amz-Date":          stamp,
+		"X-Amz-Expires":       fmt.Sprint(int(expires.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if c.SessionToken != "" {
+		q["X-Amz-Security-Token"] = c.SessionToken
+	}
+
+	canonPath, canonQuery := uriEncode(path, false), canonicalQuery(q)
+	canonReq := strings.Join([]string{
+		"GET", canonPath, canonQuery, "host:" + host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", stamp, scope, sha256Hex(canonReq)}, "\n")
+
+	k := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	k = hmacSHA256(k, c.Region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(k, toSign))
+
+	return fmt.Sprintf("%v://%v%v?%v&X-Amz-Signature=%v", scheme, host, canonPath, canonQuery, sig), nil
+}
+
+// SignedURL returns a V4 signed GET url for a GCS object.
+func (c GCSCredentials) SignedURL(bucket, object string, expires time.Duration) (string, error) {
+	return c.signedURL(bucket, object, expires, time.Now())
+}
+
+func (c GCSCredentials) signedURL(bucket, object string, expires time.Duration, now time.Time) (string, error) {
+
+	block, _ := pem.Decode(c.PrivateKey)
+	if block == nil {
+		return "", fmt.Errorf("gcs private key: no pem block")
+	}
+	var key *rsa.PrivateKey
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err == nil {
+		var ok bool
+		key, ok = parsed.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("gcs private key: not rsa")
+		}
+	} else {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("gcs private key: %v", err)
+		}
+	}
+
+	now = now.UTC()
+	date := now.Format("20060102")
+	stamp := now.Format("20060102T150405Z")
+	host := "storage.googleapis.com"
+
+	scope := fmt.Sprintf("%v/auto/storage/goog4_request", date)
+	q := map[string]string{
+		"X-Goog-Algorithm":     "GOOG4-RSA-SHA256",
+		"X-Goog-Credential":    c.ClientEmail + "/" + scope,
+		"X-Goog-Date":          stamp,
+		"X-Goog-Expires":       fmt.Sprint(int(expires.Seconds())),
+		"X-Goog-SignedHeaders": "host",
+	}
+
+	canonPath, canonQuery := uriEncode("/"+bucket+"/"+object, false), canonicalQuery(q)
+	canonReq := strings.Join([]string{
+		"GET", canonPath, canonQuery, "host:" + host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+	toSign := strings.Join([]string{"GOOG4-RSA-SHA256", stamp, scope, sha256Hex(canonReq)}, "\n")
+
+	digest := sha256.Sum256([]byte(toSign))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("https://%v%v?%v&X-Goog-Signature=%v", host, canonPath, canonQuery, hex.EncodeToString(sig)), nil
+}
+
+// uriEncode according to RFC 3986;
+// slashes are kept, unless encodeSlash.
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			sb.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func canonicalQuery(q map[string]string) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(q[k], true))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package fetch
+
+import (
+	"mime"
+	"strings"
+)
+
+// maxBytesFor is the limit for the content type ct;
+// a limit of the current call - see MustFetch() - wins if tighter.
+// Zero means unlimited.
+func (f *Job) maxBytesFor(ct string) int64 {
+	max := f.maxBytesByType(ct)
+	if f.callMaxBytes > 0 && (max <= 0 || f.callMaxBytes < max) {
+		return f.callMaxBytes
+	}
+	return max
+}
+
+// maxBytesByType resolves MaxBytesByType for the content type ct;
+// exact media types before wildcards, MaxBytes as fallback.
+func (f *Job) maxBytesByType(ct string) int64 {
+	if len(f.MaxBytesByType) == 0 {
+		return f.MaxBytes
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return f.MaxBytes
+	}
+	max, ok := f.MaxBytesByType[mt]
+	if !ok {
+		if i := strings.Index(mt, "/"); i > 0 {
+			max, ok = f.MaxBytesByType[mt[:i]+"/*"]
+		}
+	}
+	if !ok {
+		return f.MaxBytes
+	}
+	if max < 0 {
+		return 0
+	}
+	return max
+}
+
+// maxBytes for the response at hand
+func (f *Job) maxBytes() int64 {
+	ct := ""
+	if f.RespHeader != nil {
+		ct = f.RespHeader.Get("Content-Type")
+	}
+	return f.maxBytesFor(ct)
+}
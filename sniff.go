@@ -0,0 +1,45 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// sniffBody handles long tail servers sending
+// gzip without Content-Encoding, or JSON as text/html.
+func (f *Job) sniffBody() error {
+
+	if bytes.HasPrefix(f.bts, []byte{0x1f, 0x8b}) && f.decoding == "" {
+		raw := &countingReader{r: bytes.NewReader(f.bts)}
+		gz, err := gzip.NewReader(raw)
+		if err == nil {
+			var r io.Reader = &bombGuard{r: gz, compressed: raw, ratio: f.decompressRatio(), max: f.MaxDecompressed}
+			if max := f.maxBytes(); max > 0 {
+				r = &limitedReader{r: r, max: max}
+			}
+			bts, err := ioutil.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("sniffed gzip: %w", err)
+			}
+			f.event("sniff", "gunzipped %v bytes to %v despite missing content encoding", len(f.bts), len(bts))
+			f.bts = bts
+			f.decoding = "gzip"
+			f.Encoding = "gzip"
+			f.BytesDecoded = int64(len(bts))
+		}
+	}
+
+	declared := f.ContentType()
+	trimmed := bytes.TrimSpace(f.bts)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		f.SniffedType = "application/json"
+	}
+	if f.SniffedType != "" && f.SniffedType != declared {
+		f.event("sniff", "body is %v, declared as %q", f.SniffedType, declared)
+	}
+	return nil
+}
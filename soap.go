@@ -0,0 +1,154 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/zew/util"
+)
+
+type SOAPVersion int
+
+const (
+	SOAP11 SOAPVersion = iota
+	SOAP12
+)
+
+var soapNamespaces = map[SOAPVersion]string{
+	SOAP11: "http://schemas.xmlsoap.org/soap/envelope/",
+	SOAP12: "http://www.w3.org/2003/05/soap-envelope",
+}
+
+// SOAPFault is returned by SOAPCall for fault responses.
+// Field names follow SOAP 1.1; SOAP 1.2 Code/Subcode/Reason
+// are mapped onto them.
+type SOAPFault struct {
+	Code    string
+	Subcode string // SOAP 1.2 only
+	String  string
+	Actor   string
+	Detail  string // raw inner xml
+}
+
+func (f *SOAPFault) Error() string {
+	if f.Subcode != "" {
+		return fmt.Sprintf("soap fault %v/%v: %v", f.Code, f.Subcode, f.String)
+	}
+	return fmt.Sprintf("soap fault %v: %v", f.Code, f.String)
+}
+
+type soapEnvelopeOut struct {
+	XMLName xml.Name
+	Body    struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+type soapEnvelopeIn struct {
+	Body struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+type soapFaultIn struct {
+	XMLName xml.Name
+	// 1.1
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+	FaultActor  string `xml:"faultactor"`
+	Detail11    struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"detail"`
+	// 1.2
+	Code struct {
+		Value   string `xml:"Value"`
+		Subcode struct {
+			Value string `xml:"Value"`
+		} `xml:"Subcode"`
+	} `xml:"Code"`
+	Reason struct {
+		Text string `xml:"Text"`
+	} `xml:"Reason"`
+	Role     string `xml:"Role"`
+	Detail12 struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"Detail"`
+}
+
+// SOAPCall wraps in into a SOAP envelope, posts it to j.URL
+// and unmarshals the response body content into out.
+// Faults are returned as *SOAPFault.
+// All other settings of j - timeouts, headers, secrets - apply.
+func SOAPCall(j *Job, v SOAPVersion, action string, in, out interface{}) error {
+
+	inner, err := xml.Marshal(in)
+	if err != nil {
+		return err
+	}
+	env := soapEnvelopeOut{}
+	env.XMLName = xml.Name{Space: soapNamespaces[v], Local: "Envelope"}
+	env.Body.Inner = inner
+	bts, err := xml.Marshal(env)
+	if err != nil {
+		return err
+	}
+	bts = append([]byte(xml.Header), bts...)
+
+	u, err := util.UrlParseImproved(j.URL)
+	if err != nil {
+		return err
+	}
+	j.Req, err = http.NewRequest("POST", u.String(), bytes.NewReader(bts))
+	if err != nil {
+		return err
+	}
+	if v == SOAP12 {
+		ct := "application/soap+xml; charset=utf-8"
+		if action != "" {
+			ct += fmt.Sprintf("; action=%q", action)
+		}
+		j.Req.Header.Set("Content-Type", ct)
+	} else {
+		j.Req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+		j.Req.Header.Set("SOAPAction", fmt.Sprintf("%q", action))
+	}
+
+	j.Fetch()
+	if j.Err != nil {
+		return j.Err
+	}
+
+	envIn := soapEnvelopeIn{}
+	if err := newXMLDecoder(j.bts).Decode(&envIn); err != nil {
+		return fmt.Errorf("status %v; no soap envelope: %v", j.Status, err)
+	}
+
+	fault := soapFaultIn{}
+	if err := newXMLDecoder(envIn.Body.Inner).Decode(&fault); err == nil && fault.XMLName.Local == "Fault" {
+		if v == SOAP12 {
+			return &SOAPFault{
+				Code:    fault.Code.Value,
+				Subcode: fault.Code.Subcode.Value,
+				String:  fault.Reason.Text,
+				Actor:   fault.Role,
+				Detail:  fault.Detail12.Inner,
+			}
+		}
+		return &SOAPFault{
+			Code:   fault.FaultCode,
+			String: fault.FaultString,
+			Actor:  fault.FaultActor,
+			Detail: fault.Detail11.Inner,
+		}
+	}
+
+	if j.Status < 200 || j.Status > 299 {
+		return fmt.Errorf("soap call %v: %w", action, j.StatusErr())
+	}
+	if out == nil {
+		return nil
+	}
+	return newXMLDecoder(envIn.Body.Inner).Decode(out)
+}
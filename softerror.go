@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrSoftError matches any *SoftError
+var ErrSoftError = errors.New("soft error page")
+
+// SoftError is a 2xx response, which is really an error page.
+type SoftError struct {
+	Class       string // challenge, login, parked, isp ...
+	Fingerprint string // name of the matching Fingerprint
+}
+
+func (e *SoftError) Error() string {
+	return fmt.Sprintf("soft error page: %v (%v)", e.Class, e.Fingerprint)
+}
+
+func (e *SoftError) Is(target error) bool {
+	return target == ErrSoftError
+}
+
+// Fingerprint recognizes a soft error page.
+// All of its non empty criteria must match.
+type Fingerprint struct {
+	Name        string
+	Class       string
+	Header      string // response header name for HeaderMatch
+	HeaderMatch *regexp.Regexp
+	Body        *regexp.Regexp // applied to the first 64 kB
+}
+
+// DefaultFingerprints cover common soft error pages;
+// append your own or start from scratch.
+var DefaultFingerprints = []Fingerprint{
+	{Name: "cloudflare-mitigated", Class: "challenge", Header: "Cf-Mitigated", HeaderMatch: regexp.MustCompile(`(?i)challenge`)},
+	{Name: "cloudflare-challenge", Class: "challenge", Body: regexp.MustCompile(`(?i)<title>Just a moment\.\.\.</title>|/cdn-cgi/challenge-platform/`)},
+	{Name: "login-title", Class: "login", Body: regexp.MustCompile(`(?i)<title>[^<]{0,40}\b(log ?in|sign ?in|anmelden)\b[^<]{0,40}</title>`)},
+	{Name: "parked-domain", Class: "parked", Body: regexp.MustCompile(`(?i)this domain (is|may be) for sale|sedoparking|parkingcrew|bodis\.com/|domain parking`)},
+	{Name: "isp-nxdomain", Class: "isp", Body: regexp.MustCompile(`(?i)searchassist|dnsrsearch|guide\.opendns\.com|dnserrorassist`)},
+}
+
+func (fp Fingerprint) match(f *Job, head []byte) bool {
+	if fp.Header == "" && fp.Body == nil {
+		return false
+	}
+	if fp.Header != "" {
+		v := f.RespHeader.Get(fp.Header)
+		if v == "" || fp.HeaderMatch != nil && !fp.HeaderMatch.MatchString(v) {
+			return false
+		}
+	}
+	return fp.Body == nil || fp.Body.Match(head)
+}
+
+// checkSoftError classifies 2xx responses matching Fingerprints as failures
+func (f *Job) checkSoftError() error {
+	if len(f.Fingerprints) == 0 || f.Status < 200 || f.Status > 299 {
+		return nil
+	}
+	head := f.bts
+	if len(head) > 64<<10 {
+		head = head[:64<<10]
+	}
+	for _, fp := range f.Fingerprints {
+		if fp.match(f, head) {
+			f.event("softerror", "status %v, but %v page per %v", f.Status, fp.Class, fp.Name)
+			return &SoftError{Class: fp.Class, Fingerprint: fp.Name}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+)
+
+// spill writes the body into a temp file in SpillDir,
+// encrypted, if SpillKeys are set.
+func (f *Job) spill(r io.Reader) error {
+
+	fl, err := ioutil.TempFile(f.SpillDir, "fetch-*.body")
+	if err != nil {
+		return err
+	}
+	f.SpillPath = fl.Name()
+
+	var w io.WriteCloser = fl
+	if f.SpillKeys != nil {
+		w, err = NewEncryptWriter(fl, f.SpillKeys)
+		if err != nil {
+			fl.Close()
+			return f.dropSpill(err)
+		}
+	}
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		fl.Close()
+		return f.dropSpill(err)
+	}
+	if max := f.maxBytes(); max > 0 && n > max {
+		w.Close()
+		fl.Close()
+		return f.dropSpill(fmt.Errorf("%w: more than %v bytes", ErrBodyTooLarge, max))
+	}
+	if err := w.Close(); err != nil {
+		fl.Close()
+		return f.dropSpill(err)
+	}
+	if f.SpillKeys != nil {
+		if err := fl.Close(); err != nil {
+			return f.dropSpill(err)
+		}
+	}
+	f.log(slog.LevelInfo, "spilled", "bytes", n, "path", f.SpillPath)
+	return nil
+}
+
+func (f *Job) dropSpill(err error) error {
+	os.Remove(f.SpillPath)
+	f.SpillPath = ""
+	return err
+}
+
+// ReadSpill returns the body - whether it was spilled or not.
+func (j *Job) ReadSpill() ([]byte, error) {
+	if j.SpillPath == "" {
+		return j.bts, nil
+	}
+	rc, err := j.openSpill()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// spillReader decrypts; Close closes the file
+type spillReader struct {
+	io.Reader
+	fl *os.File
+}
+
+func (r *spillReader) Close() error {
+	return r.fl.Close()
+}
+
+// openSpill reads the spilled body, decrypting if need be;
+// unencrypted, it is the *os.File itself.
+func (j *Job) openSpill() (io.ReadCloser, error) {
+	fl, err := os.Open(j.SpillPath)
+	if err != nil {
+		return nil, err
+	}
+	if j.SpillKeys == nil {
+		return fl, nil
+	}
+	dr, err := NewDecryptReader(fl, j.SpillKeys)
+	if err != nil {
+		fl.Close()
+		return nil, err
+	}
+	return &spillReader{Reader: dr, fl: fl}, nil
+}
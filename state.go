@@ -0,0 +1,157 @@
+package fetch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// StateStore persists throttling state across restarts,
+// so that a crash-restart loop does not reset our politeness.
+// FileStore and RedisStore (build tag redis) implement it.
+type StateStore interface {
+	Save(key string, val []byte) error
+	Load(key string) ([]byte, error) // nil, nil if absent
+}
+
+// FileStore keeps each key in a file in Dir.
+type FileStore struct {
+	Dir string
+}
+
+func (s FileStore) Save(key string, val []byte) error {
+	tmp, err := ioutil.TempFile(s.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(val); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.Dir, key)) // atomic
+}
+
+func (s FileStore) Load(key string) ([]byte, error) {
+	bts, err := ioutil.ReadFile(filepath.Join(s.Dir, key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return bts, err
+}
+
+// HostLimit is the persisted state of one host of a Limiter.
+type HostLimit struct {
+	RPS    float64
+	Burst  int
+	Tokens float64 // at At; negative if requests are queued
+	At     time.Time
+	Paused time.Time `json:",omitempty"`
+}
+
+// LimiterState is the persisted state of a Limiter.
+type LimiterState struct {
+	Hosts map[string]HostLimit
+}
+
+// Snapshot captures the current state.
+func (l *Limiter) Snapshot() LimiterState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	st := LimiterState{Hosts: map[string]HostLimit{}}
+	for host, lim := range l.hosts {
+		if lim.Limit() == rate.Inf {
+			continue // nothing to remember
+		}
+		st.Hosts[host] = HostLimit{
+			RPS:    float64(lim.Limit()),
+			Burst:  lim.Burst(),
+			Tokens: lim.TokensAt(now),
+			At:     now,
+		}
+	}
+	for host, until := range l.paused {
+		if until.After(now) {
+			hl := st.Hosts[host]
+			hl.Paused = until
+			st.Hosts[host] = hl
+		}
+	}
+	return st
+}
+
+// Restore applies a snapshot; tokens are replenished
+// for the time passed since.
+// Hosts overridden by SetHost keep their rates.
+func (l *Limiter) Restore(st LimiterState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.hosts == nil {
+		l.hosts = map[string]*rate.Limiter{}
+	}
+	if l.paused == nil {
+		l.paused = map[string]time.Time{}
+	}
+	for host, hl := range st.Hosts {
+		if hl.Paused.After(now) {
+			l.paused[host] = hl.Paused
+		}
+		if hl.Burst < 1 || hl.RPS <= 0 {
+			continue
+		}
+		r, b := rate.Limit(hl.RPS), hl.Burst
+		if rr, ok := l.rates[host]; ok {
+			r, b = rr, l.burst[host]
+		}
+		tokens := hl.Tokens + hl.RPS*now.Sub(hl.At).Seconds()
+		if tokens > float64(b) {
+			tokens = float64(b)
+		}
+		lim := rate.NewLimiter(r, b)
+		if tokens < 0 {
+			// debt of queued requests - wait it off
+			lim.AllowN(now, b)
+			debt := time.Duration(-tokens / float64(r) * float64(time.Second))
+			if until := now.Add(debt); until.After(l.paused[host]) {
+				l.paused[host] = until
+			}
+		} else if n := b - int(tokens); n > 0 {
+			lim.AllowN(now, n)
+		}
+		l.hosts[host] = lim
+	}
+}
+
+const limiterKey = "fetch-limiter.json"
+
+// SaveLimiter persists l into s.
+func SaveLimiter(s StateStore, l *Limiter) error {
+	bts, err := json.Marshal(l.Snapshot())
+	if err != nil {
+		return err
+	}
+	return s.Save(limiterKey, bts)
+}
+
+// LoadLimiter restores l from s; absent state is no error.
+func LoadLimiter(s StateStore, l *Limiter) error {
+	bts, err := s.Load(limiterKey)
+	if err != nil || bts == nil {
+		return err
+	}
+	st := LimiterState{}
+	if err := json.Unmarshal(bts, &st); err != nil {
+		return err
+	}
+	l.Restore(st)
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build redis
+
+package fetch
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+// RedisStore keeps throttling state in Redis,
+// so that it survives restarts of any instance. Build with -tags redis.
+// Unlike RedisCache, errors are returned - a lost state is not harmless.
+type RedisStore struct {
+	Client     redis.UniversalClient
+	Prefix     string        // default "fetch:state:"
+	Expiration time.Duration // zero keeps keys forever
+	Timeout    time.Duration // per operation; default 500ms
+}
+
+// NewRedisStore with defaults.
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{Client: client}
+}
+
+func (s *RedisStore) key(key string) string {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "fetch:state:"
+	}
+	return prefix + key
+}
+
+func (s *RedisStore) ctx() (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 500 * time.Millisecond
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func (s *RedisStore) Save(key string, val []byte) error {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	return s.Client.Set(ctx, s.key(key), val, s.Expiration).Err()
+}
+
+func (s *RedisStore) Load(key string) ([]byte, error) {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	bts, err := s.Client.Get(ctx, s.key(key)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return bts, err
+}
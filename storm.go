@@ -0,0 +1,113 @@
+package fetch
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// StormGuard spreads synchronized retries against one host -
+// the thundering herd, when many jobs fail at once during an outage
+// and would all come back after the same backoff.
+// Share one instance across jobs; Batch.Storm does so.
+//
+// While more than Threshold retries per Window hit a host,
+// further retries are queued behind each other, Window/Threshold apart,
+// each with a random offset within its slot.
+type StormGuard struct {
+	Window    time.Duration // default 2 seconds
+	Threshold int           // default 5
+	MaxDelay  time.Duration // cap on the extra delay; default 2 minutes
+
+	mu    sync.Mutex
+	hosts map[string]*stormState
+}
+
+type stormState struct {
+	recent []time.Time // retries within Window
+	cursor time.Time   // next free slot during a storm
+}
+
+// NewStormGuard with defaults.
+func NewStormGuard() *StormGuard {
+	return &StormGuard{}
+}
+
+func (g *StormGuard) params() (time.Duration, int, time.Duration) {
+	window, threshold, max := g.Window, g.Threshold, g.MaxDelay
+	if window <= 0 {
+		window = 2 * time.Second
+	}
+	if threshold < 1 {
+		threshold = 5
+	}
+	if max <= 0 {
+		max = 2 * time.Minute
+	}
+	return window, threshold, max
+}
+
+// spread returns wait, stretched if host is in a retry storm
+func (g *StormGuard) spread(host string, wait time.Duration) time.Duration {
+	if g == nil || host == "" {
+		return wait
+	}
+	window, threshold, max := g.params()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.hosts == nil {
+		g.hosts = map[string]*stormState{}
+	}
+	st, ok := g.hosts[host]
+	if !ok {
+		st = &stormState{}
+		g.hosts[host] = st
+	}
+
+	now := time.Now()
+	keep := st.recent[:0]
+	for _, t := range st.recent {
+		if now.Sub(t) < window {
+			keep = append(keep, t)
+		}
+	}
+	st.recent = append(keep, now)
+	if len(st.recent) <= threshold {
+		return wait
+	}
+
+	slot := window / time.Duration(threshold)
+	due := now.Add(wait)
+	if st.cursor.Before(due) {
+		st.cursor = due
+	}
+	at := st.cursor.Add(time.Duration(rand.Int63n(int64(slot) + 1)))
+	st.cursor = st.cursor.Add(slot)
+	stretched := at.Sub(now)
+	if stretched > wait+max {
+		stretched = wait + max
+	}
+	return stretched
+}
+
+// Storming reports whether host currently sees more than Threshold retries per Window.
+func (g *StormGuard) Storming(host string) bool {
+	if g == nil {
+		return false
+	}
+	window, threshold, _ := g.params()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st, ok := g.hosts[host]
+	if !ok {
+		return false
+	}
+	n := 0
+	for _, t := range st.recent {
+		if time.Since(t) < window {
+			n++
+		}
+	}
+	return n > threshold
+}
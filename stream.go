@@ -0,0 +1,70 @@
+package fetch
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// bodyStream is the body of a streamed response
+type bodyStream struct {
+	r      io.Reader
+	body   io.ReadCloser
+	cancel func()
+}
+
+func (s *bodyStream) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+func (s *bodyStream) Close() error {
+	err := s.body.Close()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return err
+}
+
+// limitedReader fails with ErrBodyTooLarge
+// instead of a silent EOF.
+type limitedReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, fmt.Errorf("%w: more than %v bytes", ErrBodyTooLarge, l.max)
+	}
+	return n, err
+}
+
+func (f *Job) openStream(resp *http.Response) error {
+	r, err := f.decodedBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+	if max := f.maxBytes(); max > 0 {
+		r = &limitedReader{r: r, max: max}
+	}
+	f.stream = &bodyStream{r: r, body: resp.Body}
+	return nil
+}
+
+// Body returns the response body for Stream jobs.
+// The caller must close it.
+// For other jobs it returns the slurped bytes.
+// Multi hundred megabyte downloads should use Stream;
+// Bytes() remains for small responses.
+func (j *Job) Body() io.ReadCloser {
+	if j.stream != nil {
+		return j.stream
+	}
+	return ioutil.NopCloser(bytes.NewReader(j.bts))
+}
@@ -0,0 +1,168 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary reports a batch run.
+type Summary struct {
+	Jobs     int
+	Fetched  int
+	Skipped  int
+	Failed   int
+	Bytes    int64 // decoded
+	Wire     int64 // received, compressed
+	Duration time.Duration
+
+	Statuses   map[int]int               // status code => count; 0 for no response
+	HostErrors map[string]map[string]int // host => error => count
+	Slowest    []SlowURL                 // top ten
+}
+
+// SlowURL is a Summary entry.
+type SlowURL struct {
+	URL     string
+	Elapsed time.Duration
+	Status  int
+}
+
+// Summary of the last Run() or Stream().
+func (b *Batch) Summary() *Summary {
+	s := Summarize(b.Jobs)
+	if !b.started.IsZero() {
+		s.Duration = b.finished.Sub(b.started)
+		if b.finished.IsZero() {
+			s.Duration = time.Since(b.started)
+		}
+	}
+	return s
+}
+
+// Summarize any jobs; Duration remains empty.
+func Summarize(jobs []*Job) *Summary {
+	s := &Summary{
+		Jobs:       len(jobs),
+		Statuses:   map[int]int{},
+		HostErrors: map[string]map[string]int{},
+	}
+	for _, j := range jobs {
+		if j.Skipped != "" {
+			s.Skipped++
+			continue
+		}
+		if j.started.IsZero() {
+			if j.Err != nil {
+				s.Failed++ // cancelled before start
+			}
+			continue
+		}
+		s.Fetched++
+		s.Statuses[j.Status]++
+		s.Bytes += int64(len(j.bts))
+		s.Wire += j.BytesOnWire
+		if j.Err != nil {
+			s.Failed++
+			host := jobHost(j)
+			if s.HostErrors[host] == nil {
+				s.HostErrors[host] = map[string]int{}
+			}
+			s.HostErrors[host][j.Err.Error()]++
+		}
+		s.Slowest = append(s.Slowest, SlowURL{URL: j.URL, Elapsed: j.Elapsed, Status: j.Status})
+	}
+	sort.SliceStable(s.Slowest, func(i, k int) bool {
+		return s.Slowest[i].Elapsed > s.Slowest[k].Elapsed
+	})
+	if len(s.Slowest) > 10 {
+		s.Slowest = s.Slowest[:10]
+	}
+	return s
+}
+
+func (s *Summary) statusCodes() []int {
+	codes := []int{}
+	for code := range s.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+func (s *Summary) hosts() []string {
+	hosts := []string{}
+	for host := range s.HostErrors {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Text renders a plain text report.
+func (s *Summary) Text() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%v jobs, %v fetched, %v skipped, %v failed\n", s.Jobs, s.Fetched, s.Skipped, s.Failed)
+	fmt.Fprintf(b, "%v bytes, %v on the wire, in %v\n", s.Bytes, s.Wire, s.Duration)
+	fmt.Fprintf(b, "statuses\n")
+	for _, code := range s.statusCodes() {
+		fmt.Fprintf(b, "\t%3v  %v\n", code, s.Statuses[code])
+	}
+	if len(s.HostErrors) > 0 {
+		fmt.Fprintf(b, "errors\n")
+		for _, host := range s.hosts() {
+			fmt.Fprintf(b, "\t%v\n", host)
+			for err, cnt := range s.HostErrors[host] {
+				fmt.Fprintf(b, "\t\t%4v  %v\n", cnt, err)
+			}
+		}
+	}
+	fmt.Fprintf(b, "slowest\n")
+	for _, su := range s.Slowest {
+		fmt.Fprintf(b, "\t%10v  %3v  %v\n", su.Elapsed.Round(time.Millisecond), su.Status, su.URL)
+	}
+	return b.String()
+}
+
+// JSON renders an indented JSON report.
+func (s *Summary) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+var summaryTpl = template.Must(template.New("summary").Parse(`<table>
+<tr><td>jobs</td><td>{{.S.Jobs}}</td></tr>
+<tr><td>fetched</td><td>{{.S.Fetched}}</td></tr>
+<tr><td>skipped</td><td>{{.S.Skipped}}</td></tr>
+<tr><td>failed</td><td>{{.S.Failed}}</td></tr>
+<tr><td>bytes</td><td>{{.S.Bytes}}</td></tr>
+<tr><td>on the wire</td><td>{{.S.Wire}}</td></tr>
+<tr><td>duration</td><td>{{.S.Duration}}</td></tr>
+</table>
+<h3>Statuses</h3>
+<table>
+{{range .Codes}}<tr><td>{{.}}</td><td>{{index $.S.Statuses .}}</td></tr>
+{{end}}</table>
+{{if .Hosts}}<h3>Errors</h3>
+<table>
+{{range $host := .Hosts}}{{range $err, $cnt := index $.S.HostErrors $host}}<tr><td>{{$host}}</td><td>{{$cnt}}</td><td>{{$err}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}<h3>Slowest</h3>
+<table>
+{{range .S.Slowest}}<tr><td>{{.Elapsed}}</td><td>{{.Status}}</td><td>{{.URL}}</td></tr>
+{{end}}</table>
+`))
+
+// HTML renders an html fragment of tables.
+func (s *Summary) HTML() (string, error) {
+	b := &bytes.Buffer{}
+	err := summaryTpl.Execute(b, map[string]interface{}{
+		"S":     s,
+		"Codes": s.statusCodes(),
+		"Hosts": s.hosts(),
+	})
+	return b.String(), err
+}
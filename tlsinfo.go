@@ -0,0 +1,65 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// TLSInfo describes the TLS connection of the final response.
+type TLSInfo struct {
+	Version     string // "TLS 1.3"
+	Protocol    string // negotiated via ALPN, "h2" or "http/1.1"; empty if none
+	CipherSuite string
+	ServerName  string
+	Resumed     bool
+	Chain       []CertInfo // leaf first
+}
+
+// CertInfo is a certificate of the peer chain
+type CertInfo struct {
+	Subject   string
+	Issuer    string
+	DNSNames  []string `json:",omitempty"`
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// LeafExpiry is NotAfter of the server certificate.
+func (t *TLSInfo) LeafExpiry() time.Time {
+	if t == nil || len(t.Chain) == 0 {
+		return time.Time{}
+	}
+	return t.Chain[0].NotAfter
+}
+
+// ExpiresWithin tells whether the server certificate expires within d,
+// i.e. to alert 14 days ahead.
+func (t *TLSInfo) ExpiresWithin(d time.Duration) bool {
+	exp := t.LeafExpiry()
+	return !exp.IsZero() && time.Until(exp) < d
+}
+
+func tlsInfo(resp *http.Response) *TLSInfo {
+	if resp.TLS == nil {
+		return nil
+	}
+	cs := resp.TLS
+	t := &TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		Protocol:    cs.NegotiatedProtocol,
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ServerName:  cs.ServerName,
+		Resumed:     cs.DidResume,
+	}
+	for _, c := range cs.PeerCertificates {
+		t.Chain = append(t.Chain, CertInfo{
+			Subject:   c.Subject.String(),
+			Issuer:    c.Issuer.String(),
+			DNSNames:  c.DNSNames,
+			NotBefore: c.NotBefore,
+			NotAfter:  c.NotAfter,
+		})
+	}
+	return t
+}
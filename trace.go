@@ -0,0 +1,145 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ResolvedAddr is an IP address a host name resolved to
+type ResolvedAddr struct {
+	IP   string
+	Used bool // the connection went to this address
+}
+
+func (a ResolvedAddr) String() string {
+	if a.Used {
+		return a.IP + " (used)"
+	}
+	return a.IP
+}
+
+// jobTrace collects httptrace callbacks.
+// These may fire on transport goroutines,
+// thus we guard with a mutex and copy into the job afterwards.
+type jobTrace struct {
+	mu       sync.Mutex
+	resolved []string
+	used     []string
+	reused   bool   // the most recent connection came from the idle pool
+	local    string // local address of the most recent connection
+
+	// of the most recent request
+	dnsStart, dnsDone   time.Time
+	connStart, connDone time.Time
+	tlsStart, tlsDone   time.Time
+	wrote, firstByte    time.Time
+}
+
+func (t *jobTrace) stamp(ts *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*ts = time.Now()
+}
+
+func span(from, to time.Time) time.Duration {
+	if from.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+func (t *jobTrace) lastReused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reused
+}
+
+func (f *Job) attachTrace() *jobTrace {
+	f.Addrs = nil
+	t := &jobTrace{}
+	ct := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// a new request - possibly a redirect hop
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connStart, t.connDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.wrote, t.firstByte = time.Time{}, time.Time{}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.stamp(&t.dnsStart) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsDone = time.Now()
+			for _, a := range info.Addrs {
+				t.resolved = append(t.resolved, a.IP.String())
+			}
+		},
+		ConnectStart:         func(string, string) { t.stamp(&t.connStart) },
+		ConnectDone:          func(string, string, error) { t.stamp(&t.connDone) },
+		TLSHandshakeStart:    func() { t.stamp(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.stamp(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.stamp(&t.wrote) },
+		GotFirstResponseByte: func() { t.stamp(&t.firstByte) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Conn == nil {
+				return
+			}
+			host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String())
+			if err != nil {
+				return
+			}
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.used = append(t.used, host)
+			t.reused = info.Reused
+			t.local = info.Conn.LocalAddr().String()
+		},
+	}
+	f.Req = f.Req.WithContext(httptrace.WithClientTrace(f.Req.Context(), ct))
+	return t
+}
+
+// collect copies the results into f.
+// With redirects, the addresses of all hops are listed.
+func (t *jobTrace) collect(f *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f.localAddr = t.local
+	f.Timings.DNS = span(t.dnsStart, t.dnsDone)
+	f.Timings.Connect = span(t.connStart, t.connDone)
+	f.Timings.TLS = span(t.tlsStart, t.tlsDone)
+	f.Timings.TTFB = span(t.wrote, t.firstByte)
+	f.Timings.Download = 0
+	if f.stream == nil {
+		f.Timings.Download = span(t.firstByte, time.Now())
+	}
+	used := map[string]bool{}
+	for _, ip := range t.used {
+		used[ip] = true
+	}
+	seen := map[string]bool{}
+	for _, ip := range t.resolved {
+		if seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		f.Addrs = append(f.Addrs, ResolvedAddr{IP: ip, Used: used[ip]})
+	}
+	// reused connections or IP literals - no DNS lookup
+	for _, ip := range t.used {
+		if !seen[ip] {
+			seen[ip] = true
+			f.Addrs = append(f.Addrs, ResolvedAddr{IP: ip, Used: true})
+		}
+	}
+	if len(f.Addrs) > 0 {
+		f.log(slog.LevelDebug, "addrs", "addrs", fmt.Sprint(f.Addrs))
+	}
+}
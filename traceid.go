@@ -0,0 +1,37 @@
+package fetch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// newTraceID is W3C trace context compatible: 16 random bytes in hex
+func newTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// assignTraceID at the start of each fetch.
+// The trace of an OpenTelemetry span in ctx wins,
+// so that logs and spans correlate; a differing TraceID of the caller is logged.
+// Else a TraceID set by the caller is kept;
+// else a random one.
+func (f *Job) assignTraceID(ctx context.Context) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		spanID := sc.TraceID().String()
+		if f.TraceID != "" && !f.traceGenerated && f.TraceID != spanID {
+			f.log(slog.LevelInfo, "trace id of the span replaces ours", "span_trace_id", spanID, "caller_trace_id", f.TraceID)
+		}
+		f.TraceID, f.traceGenerated = spanID, true
+		return
+	}
+	if f.TraceID != "" && !f.traceGenerated {
+		return
+	}
+	f.TraceID, f.traceGenerated = newTraceID(), true
+}
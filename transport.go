@@ -0,0 +1,187 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// transportKey holds the settings a customized transport is built from
+type transportKey struct {
+	serverless bool
+	localIP    string
+	maxHeader  int64
+	proxy      string
+	hostHeader string
+	dialedHost string // HostHeader is presented as SNI only to this host
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = map[transportKey]*http.Transport{}
+)
+
+// maxTransports bounds the cache of customized transports;
+// beyond, their idle connections are closed and the cache starts over.
+const maxTransports = 256
+
+// transport returns a customized transport for the standard client.
+// Nil means the client's transport is fine as is.
+// Customized transports are shared by all jobs with the same settings,
+// so that connections are reused - and not leaked per job.
+func (f *Job) transport() (http.RoundTripper, error) {
+	env := f.env()
+	if f.HostHeader == "" && f.LocalAddr == "" && f.MaxHeaderBytes == 0 && f.Proxy == "" {
+		if env.Serverless() {
+			f.log(slog.LevelDebug, "serverless mode", "env", env)
+			return serverlessTransport(env), nil
+		}
+		return nil, nil
+	}
+
+	key := transportKey{serverless: env.Serverless(), maxHeader: f.MaxHeaderBytes, proxy: f.Proxy}
+	if f.LocalAddr != "" {
+		ip, err := localIP(f.LocalAddr, f.Req.URL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		key.localIP = ip.String()
+		f.log(slog.LevelInfo, "binding to source ip", "ip", key.localIP)
+	}
+	if f.HostHeader != "" {
+		key.hostHeader, key.dialedHost = f.HostHeader, f.Req.URL.Hostname()
+	}
+	var pu *url.URL
+	if f.Proxy != "" {
+		var err error
+		pu, err = url.Parse(f.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %v", err)
+		}
+		switch pu.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("proxy: unsupported scheme %q", pu.Scheme)
+		}
+		f.log(slog.LevelInfo, "via proxy", "proxy", pu.Redacted())
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if tr, ok := transports[key]; ok {
+		return tr, nil
+	}
+	tr := newTransport(key, pu, env)
+	if len(transports) >= maxTransports {
+		for _, old := range transports {
+			old.CloseIdleConnections()
+		}
+		transports = map[transportKey]*http.Transport{}
+	}
+	transports[key] = tr
+	return tr, nil
+}
+
+func newTransport(key transportKey, proxy *url.URL, env Environment) *http.Transport {
+	// as http.DefaultTransport
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if key.localIP != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(key.localIP)}
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if key.serverless {
+		tr = serverlessTransport(env).Clone()
+		dialer.Timeout = ServerlessDialTimeout
+	}
+	tr.DialContext = dialer.DialContext
+	if key.maxHeader > 0 {
+		tr.MaxResponseHeaderBytes = key.maxHeader
+	}
+	if proxy != nil {
+		tr.Proxy = http.ProxyURL(proxy) // credentials in the userinfo are handled by the stdlib
+	}
+	if key.hostHeader != "" {
+		tr.DialTLSContext = dialTLS(tr, dialer, key.dialedHost, key.hostHeader)
+	}
+	return tr
+}
+
+// localIP takes an IP literal - or an interface name,
+// from which we take the first address,
+// preferring the address family of the target host.
+func localIP(addr, target string) (net.IP, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip, nil
+	}
+	ifc, err := net.InterfaceByName(addr)
+	if err != nil {
+		return nil, fmt.Errorf("local addr %q: %v", addr, err)
+	}
+	addrs, err := ifc.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("local addr %q: %v", addr, err)
+	}
+	wantV4 := true
+	if tip := net.ParseIP(target); tip != nil && tip.To4() == nil {
+		wantV4 = false
+	}
+	var fallback net.IP
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if (ipn.IP.To4() != nil) == wantV4 {
+			return ipn.IP, nil
+		}
+		if fallback == nil {
+			fallback = ipn.IP
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("local addr %q: interface has no usable address", addr)
+	}
+	return fallback, nil
+}
+
+// dialTLS presents HostHeader as SNI server name,
+// but only to the originally dialed host.
+// Redirects to other hosts get their own name.
+func dialTLS(tr *http.Transport, dialer *net.Dialer, dialedHost, hostHeader string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	sni, _, err := net.SplitHostPort(hostHeader)
+	if err != nil {
+		sni = hostHeader
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := &tls.Config{}
+		if tr.TLSClientConfig != nil {
+			cfg = tr.TLSClientConfig.Clone()
+		}
+		cfg.ServerName = host
+		if host == dialedHost {
+			cfg.ServerName = sni
+		}
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
@@ -0,0 +1,35 @@
+package fetch
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Verbosity keeps Msg and Events only for fetches worth debugging -
+// failed or slow ones - and for a sample of the others.
+// High QPS pipelines thus keep memory and log volume low.
+type Verbosity struct {
+	SlowerThan     time.Duration // fetches slower than this keep their diagnostics; 0 means none is slow
+	SuccessPercent float64       // share of fast successes keeping their diagnostics; 0..100
+}
+
+// keep decides for a finished job
+func (v *Verbosity) keep(f *Job) bool {
+	if f.Err != nil || f.Status >= 400 {
+		return true
+	}
+	if v.SlowerThan > 0 && f.Elapsed > v.SlowerThan {
+		return true
+	}
+	return v.SuccessPercent > 0 && rand.Float64()*100 < v.SuccessPercent
+}
+
+// trimDiagnostics drops Msg and Events, unless Verbosity keeps them.
+func (f *Job) trimDiagnostics() {
+	if f.Verbosity == nil || f.Verbosity.keep(f) {
+		return
+	}
+	f.Msg = ""
+	f.Events = nil
+	f.Trimmed = true
+}
@@ -0,0 +1,76 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// WarmUpFunc prepares a session with host,
+// i.e. fetching the landing page and accepting the cookie banner.
+// Cookies go into jar; the returned headers - tokens - are added
+// to every job of the batch against that host.
+type WarmUpFunc func(ctx context.Context, host string, jar http.CookieJar) (http.Header, error)
+
+// WarmUpURLs returns a WarmUpFunc fetching urls in order,
+// sharing the batch jar. Each url may contain "%v" for the host.
+func WarmUpURLs(urls ...string) WarmUpFunc {
+	return func(ctx context.Context, host string, jar http.CookieJar) (http.Header, error) {
+		for _, u := range urls {
+			j := New(fmt.Sprintf(u, host), WithJar(jar))
+			j.FetchContext(ctx)
+			if j.Err != nil {
+				return nil, fmt.Errorf("warm up %v: %w", j.URL, j.Err)
+			}
+			if j.Status > 399 {
+				return nil, fmt.Errorf("warm up %v: status %v", j.URL, j.Status)
+			}
+		}
+		return nil, nil
+	}
+}
+
+type warmState struct {
+	once   sync.Once
+	header http.Header
+	err    error
+}
+
+// warmUp runs WarmUp once per host of the batch;
+// jobs against the same host wait for it.
+// A failed warm up fails all jobs against the host.
+func (b *Batch) warmUp(ctx context.Context, j *Job) error {
+	if b.WarmUp == nil {
+		return nil
+	}
+	host := targetHost(j)
+	b.mu.Lock()
+	if b.warm == nil {
+		b.warm = map[string]*warmState{}
+	}
+	ws, ok := b.warm[host]
+	if !ok {
+		ws = &warmState{}
+		b.warm[host] = ws
+	}
+	b.mu.Unlock()
+
+	ws.once.Do(func() {
+		ws.header, ws.err = b.WarmUp(ctx, host, j.Jar)
+	})
+	if ws.err != nil {
+		return ws.err
+	}
+	for k, vals := range ws.header {
+		if j.Headers.Get(k) != "" {
+			continue
+		}
+		for _, v := range vals {
+			j.AddHeader(k, v)
+		}
+	}
+	j.event("warmup", "session with %v warmed up", host)
+	return nil
+}
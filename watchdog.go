@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var ErrWatchdog = errors.New("fetch stuck beyond deadline; cancelled by watchdog")
+
+// maxStackDump limits the goroutine dump stored into the events
+var maxStackDump = 256 * 1024
+
+// startWatchdog guards against transports
+// hanging beyond their deadline.
+// When Timeout + Watchdog has passed, all goroutine stacks
+// are captured into the events, and the request is cancelled.
+// The returned func must be called after the fetch returned.
+func (f *Job) startWatchdog() func() {
+	if f.Watchdog <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(f.Req.Context())
+	f.Req = f.Req.WithContext(ctx)
+
+	limit := f.Timeout*time.Second + f.Watchdog
+	fired := make(chan []byte, 1)
+	timer := time.AfterFunc(limit, func() {
+		buf := make([]byte, maxStackDump)
+		buf = buf[:runtime.Stack(buf, true)]
+		fired <- buf
+		cancel()
+	})
+
+	return func() {
+		timer.Stop()
+		select {
+		case stack := <-fired:
+			f.event("watchdog", "stuck after %v; goroutines:\n%s", limit, stack)
+			f.log(slog.LevelError, "watchdog cancelled fetch", "after", limit)
+			f.Err = fmt.Errorf("%w (%v): %v", ErrWatchdog, limit, f.Err)
+		default:
+		}
+		if f.stream != nil {
+			f.stream.cancel = cancel // streamed body is still being read
+			return
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// WaybackAPI is the availability API of the Internet Archive
+var WaybackAPI = "https://archive.org/wayback/available"
+
+// ArchiveInfo marks a result served from a web archive.
+type ArchiveInfo struct {
+	URL       string    // of the snapshot
+	Timestamp time.Time // of the snapshot
+	Status    int       // the origin's status; 404 or 410
+}
+
+// WithWayback serves archived snapshots for vanished pages.
+func WithWayback() Option {
+	return func(j *Job) {
+		j.Wayback = true
+	}
+}
+
+type waybackAvailable struct {
+	ArchivedSnapshots struct {
+		Closest struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// waybackFallback replaces a 404 or 410 response
+// by the latest archived snapshot - if there is one.
+func (f *Job) waybackFallback(ctx context.Context) {
+	if !f.Wayback || f.Err != nil || (f.Status != 404 && f.Status != 410) || ctx.Err() != nil {
+		return
+	}
+	target := f.URL
+	if f.Req != nil {
+		target = f.Req.URL.String()
+	}
+	if f.primary != nil {
+		target = f.primary.String()
+	}
+	// the archive gets neither credentials nor secret params
+	if u, err := url.Parse(target); err == nil {
+		u.User = nil
+		target = f.redactURL(u.String())
+	}
+
+	api := New(WaybackAPI+"?url="+url.QueryEscape(target), WithTimeout(f.Timeout*time.Second))
+	api.AeReq, api.Proxy, api.TraceID = f.AeReq, f.Proxy, f.TraceID
+	api.FetchContext(ctx)
+	avail := waybackAvailable{}
+	if api.Err != nil || api.Status != 200 || json.Unmarshal(api.bts, &avail) != nil {
+		f.log(slog.LevelWarn, "wayback availability failed", "status", api.Status, "err", api.Err)
+		return
+	}
+	closest := avail.ArchivedSnapshots.Closest
+	if !closest.Available || closest.Status != "200" {
+		f.event("wayback", "no archived snapshot for %v", target)
+		return
+	}
+	ts, err := time.Parse("20060102150405", closest.Timestamp)
+	if err != nil {
+		return
+	}
+
+	// id_ serves the original bytes, without the archive's toolbar and link rewriting
+	raw := strings.Replace(closest.URL, "/"+closest.Timestamp+"/", "/"+closest.Timestamp+"id_/", 1)
+	snap := New(raw, WithTimeout(f.Timeout*time.Second), WithMaxBytes(f.MaxBytes))
+	snap.AeReq, snap.Proxy, snap.TraceID = f.AeReq, f.Proxy, f.TraceID
+	snap.Stream = f.Stream
+	snap.FetchContext(ctx)
+	if snap.Err != nil || snap.Status != 200 {
+		if snap.stream != nil {
+			snap.stream.Close()
+		}
+		f.log(slog.LevelWarn, "wayback snapshot failed", "status", snap.Status, "err", snap.Err)
+		return
+	}
+	if f.stream != nil {
+		f.stream.Close() // the body of the 404
+	}
+	f.stream = snap.stream
+
+	f.Archived = &ArchiveInfo{URL: closest.URL, Timestamp: ts, Status: f.Status}
+	f.Status = snap.Status
+	f.RespHeader = snap.RespHeader
+	f.bts = snap.bts
+	f.BytesOnWire, f.BytesDecoded, f.Encoding = snap.BytesOnWire, snap.BytesDecoded, snap.Encoding
+	f.Mod = ts
+	f.event("wayback", "origin answered %v; served snapshot of %v", f.Archived.Status, ts.Format(time.RFC3339))
+	f.log(slog.LevelInfo, "served from wayback", "snapshot", closest.URL)
+}
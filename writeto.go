@@ -0,0 +1,76 @@
+package fetch
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// hopHeaders are not forwarded; see RFC 7230 section 6.1.
+// Set-Cookie of the upstream must not land in our clients' jars.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Set-Cookie", "Strict-Transport-Security", "Alt-Svc",
+}
+
+// WriteTo copies the response to w.
+// If w is an http.ResponseWriter, status and safe headers are copied as well,
+// making pass-through handlers a one liner:
+//
+//	j := fetch.New(url, fetch.WithAppengine(r))
+//	j.Stream = true
+//	j.Fetch()
+//	j.WriteTo(w)
+//
+// Streamed bodies are copied as they arrive and closed;
+// spilled bodies are copied from their file.
+// Content-Encoding and Content-Length are dropped, if we decoded the body.
+func (j *Job) WriteTo(w io.Writer) (int64, error) {
+	if j.Err != nil {
+		if rw, ok := w.(http.ResponseWriter); ok {
+			http.Error(rw, "upstream: "+j.Err.Error(), http.StatusBadGateway)
+		}
+		return 0, j.Err
+	}
+
+	if rw, ok := w.(http.ResponseWriter); ok {
+		hdr := rw.Header()
+		for k, vals := range j.RespHeader {
+			hdr[k] = append([]string(nil), vals...)
+		}
+		for _, v := range j.RespHeader.Values("Connection") {
+			for _, k := range strings.Split(v, ",") {
+				hdr.Del(strings.TrimSpace(k))
+			}
+		}
+		for _, k := range hopHeaders {
+			hdr.Del(k)
+		}
+		if j.decoding != "" {
+			hdr.Del("Content-Encoding")
+			hdr.Del("Content-Length")
+		}
+		status := j.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		rw.WriteHeader(status)
+	}
+
+	var body io.Reader = bytes.NewReader(j.bts)
+	switch {
+	case j.stream != nil:
+		defer j.stream.Close()
+		body = j.stream
+	case j.SpillPath != "":
+		rc, err := j.openSpill()
+		if err != nil {
+			return 0, err
+		}
+		defer rc.Close()
+		body = rc
+	}
+	return io.Copy(w, body)
+}
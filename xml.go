@@ -0,0 +1,107 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// XMLCheck demands presence of an element -
+// or of an attribute on that element.
+// A poor man's XSD.
+type XMLCheck struct {
+	Path string // slash separated local names from the root, i.e. "rss/channel/item"
+	Attr string // optional attribute local name
+}
+
+func (c XMLCheck) String() string {
+	if c.Attr != "" {
+		return c.Path + "@" + c.Attr
+	}
+	return c.Path
+}
+
+// FetchXML fetches j and unmarshals the body into T.
+// Non UTF-8 declarations such as <?xml encoding="ISO-8859-1"?>
+// are converted, instead of being rejected by encoding/xml.
+func FetchXML[T any](j *Job, checks ...XMLCheck) (T, error) {
+	var t T
+	j.Fetch()
+	if j.Err != nil {
+		return t, j.Err
+	}
+	if j.Status < 200 || j.Status > 299 {
+		return t, j.StatusErr()
+	}
+	if err := CheckXML(j.bts, checks...); err != nil {
+		return t, err
+	}
+	dec := newXMLDecoder(j.bts)
+	if err := dec.Decode(&t); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+func newXMLDecoder(bts []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(bts))
+	dec.CharsetReader = charset.NewReaderLabel
+	return dec
+}
+
+// CheckXML returns an error listing all checks
+// not satisfied by the document.
+func CheckXML(bts []byte, checks ...XMLCheck) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	found := make([]bool, len(checks))
+
+	dec := newXMLDecoder(bts)
+	stack := []string{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, el.Name.Local)
+			path := strings.Join(stack, "/")
+			for i, c := range checks {
+				if found[i] || c.Path != path {
+					continue
+				}
+				if c.Attr == "" {
+					found[i] = true
+					continue
+				}
+				for _, a := range el.Attr {
+					if a.Name.Local == c.Attr {
+						found[i] = true
+					}
+				}
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	missing := []string{}
+	for i, c := range checks {
+		if !found[i] {
+			missing = append(missing, c.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("xml lacks %v", strings.Join(missing, ", "))
+	}
+	return nil
+}